@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/converged-computing/rainbow/pkg/certs"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/plugins/backends/memory"
+
+	// Register match algorithms used by the graph
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/compatibility"
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+var (
+	host string
+
+	// default logging level of warning (none, info, warning)
+	loggingLevel = 3
+	caCertFile   = ""
+	certFile     = ""
+	keyFile      = ""
+	token        = ""
+	backupFile   = ""
+	insecure     = false
+)
+
+func main() {
+	flag.StringVar(&host, "host", ":50052", "Graph server address (host:port)")
+	flag.StringVar(&token, "token", token, "token required from rainbow to access the graph")
+	flag.BoolVar(&insecure, "insecure", insecure, "serve the graph without a token, so any client can access it")
+	flag.StringVar(&backupFile, "backup-file", backupFile, "file to load and save the graph state")
+	flag.StringVar(&caCertFile, "ca-cert", caCertFile, "Server certificate file for TLS (e.g., ca-cert.pem)")
+	flag.StringVar(&certFile, "cert", certFile, "Server certificate file for TLS (e.g., server-cert.pem)")
+	flag.StringVar(&keyFile, "key", keyFile, "Server key file for TLS (e.g., server-key.pem)")
+	flag.IntVar(&loggingLevel, "loglevel", loggingLevel, "rainbow logging level (0 to 5)")
+	flag.Parse()
+
+	// If the logging level isn't the default, set it
+	if loggingLevel != rlog.DefaultLevel {
+		rlog.SetLevel(loggingLevel)
+	}
+
+	// Generate certificate manager
+	cert, err := certs.NewServerCertificate(caCertFile, certFile, keyFile)
+	if err != nil {
+		log.Fatalf("error creating certificate manager: %v", err)
+	}
+	if token == "" && !insecure {
+		log.Fatalf("a -token is required to serve the graph (or -insecure to allow any client)")
+	}
+	if token == "" {
+		log.Printf("⚠️ WARNING: no token is set, any client can access the graph.")
+	}
+
+	server, err := memory.NewGraphServer(cert, token, backupFile, insecure)
+	if err != nil {
+		log.Fatalf("error while creating graph server: %v", err)
+	}
+	lis, err := net.Listen("tcp", host)
+	if err != nil {
+		log.Fatalf("error while listening on %s: %v", host, err)
+	}
+
+	log.Printf("🧠️ starting memory graph server: %s", host)
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("error while running graph server: %v", err)
+	}
+}
@@ -17,10 +17,12 @@ import (
 	"github.com/converged-computing/rainbow/pkg/types"
 
 	// Register database backends and selection algorithms
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/compatibility"
 	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
 	_ "github.com/converged-computing/rainbow/plugins/backends/memgraph"
 	_ "github.com/converged-computing/rainbow/plugins/backends/memory"
 	_ "github.com/converged-computing/rainbow/plugins/backends/neo4j"
+	_ "github.com/converged-computing/rainbow/plugins/backends/sqlite"
 	_ "github.com/converged-computing/rainbow/plugins/selection/constraint"
 	_ "github.com/converged-computing/rainbow/plugins/selection/random"
 )
@@ -97,6 +99,7 @@ func main() {
 	command := submitCmd.String("c", "command", &argparse.Options{Default: defaultSecret, Help: "Command to submit"})
 	jobName := submitCmd.String("", "job-name", &argparse.Options{Help: "Name for the job (defaults to first command)"})
 	jobspec := submitCmd.String("", "jobspec", &argparse.Options{Help: "A yaml Jobspec to submit"})
+	explain := submitCmd.Flag("", "explain", &argparse.Options{Help: "Show why clusters do not satisfy the job"})
 
 	// Now parse the arguments
 	err := parser.Parse(os.Args)
@@ -207,6 +210,7 @@ func main() {
 			*cfg,
 			*selectAlgo,
 			*matchAlgo,
+			*explain,
 		)
 		if err != nil {
 			log.Fatal(err.Error())
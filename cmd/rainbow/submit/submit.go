@@ -20,6 +20,7 @@ func Run(
 	token, jobspec, clusterName,
 	database, cfgFile string,
 	selectAlgo, matchAlgo string,
+	explain bool,
 ) error {
 
 	var err error
@@ -55,7 +56,7 @@ func Run(
 	cfg.AddCluster(clusterName, token)
 
 	// Submission is always with a configuration
-	response, err := c.SubmitJob(context.Background(), jspec, cfg)
+	response, err := c.SubmitJob(context.Background(), jspec, cfg, explain)
 	if err != nil {
 		return err
 	}
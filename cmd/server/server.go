@@ -12,10 +12,12 @@ import (
 	"github.com/converged-computing/rainbow/pkg/types"
 
 	// Register database backends
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/compatibility"
 	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
 	_ "github.com/converged-computing/rainbow/plugins/backends/memgraph"
 	_ "github.com/converged-computing/rainbow/plugins/backends/memory"
 	_ "github.com/converged-computing/rainbow/plugins/backends/neo4j"
+	_ "github.com/converged-computing/rainbow/plugins/backends/sqlite"
 	_ "github.com/converged-computing/rainbow/plugins/selection/constraint"
 	_ "github.com/converged-computing/rainbow/plugins/selection/random"
 )
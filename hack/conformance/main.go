@@ -0,0 +1,91 @@
+package main
+
+// Check that a graph backend behaves like the others, using the examples.
+// The backend service (if it has one) is served for the checks.
+// go run ./hack/conformance --backend memory
+// go run ./hack/conformance --backend sqlite --option file=/tmp/conformance.db
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend"
+	"github.com/converged-computing/rainbow/pkg/graph/backend/backendtest"
+	"google.golang.org/grpc"
+
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/compatibility"
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+	_ "github.com/converged-computing/rainbow/plugins/backends/memgraph"
+	_ "github.com/converged-computing/rainbow/plugins/backends/memory"
+	_ "github.com/converged-computing/rainbow/plugins/backends/neo4j"
+	_ "github.com/converged-computing/rainbow/plugins/backends/sqlite"
+)
+
+// options are key=value pairs for the backend, and can be repeated
+type options map[string]string
+
+func (o options) String() string {
+	return fmt.Sprintf("%v", map[string]string(o))
+}
+
+func (o options) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("option %s is not key=value", value)
+	}
+	o[key] = val
+	return nil
+}
+
+const conformanceToken = "backendtest"
+
+var (
+	backendName = flag.String("backend", "memory", "name of the graph backend to check")
+	examples    = flag.String("examples", "docs/examples", "directory with examples")
+	host        = flag.String("host", "127.0.0.1:50061", "host to serve the backend service")
+	matcher     = flag.String("match-algorithm", "match", "match algorithm to satisfy with")
+)
+
+func main() {
+	backendOptions := options{}
+	flag.Var(backendOptions, "option", "backend option as key=value (can be repeated)")
+	flag.Parse()
+
+	graphDB := backend.GetOrFail(*backendName)
+	matchAlgo := algorithm.GetOrFail(*matcher)
+
+	// The memory backend reaches its service at the host
+	_, ok := backendOptions["host"]
+	if !ok {
+		backendOptions["host"] = *host
+	}
+	err := graphDB.Init(backendOptions)
+	if err != nil {
+		log.Fatalf("cannot initialize backend %s: %s", *backendName, err)
+	}
+
+	lis, err := net.Listen("tcp", backendOptions["host"])
+	if err != nil {
+		log.Fatalf("cannot listen on %s: %s", backendOptions["host"], err)
+	}
+	// Satisfy requests send the same token for every cluster
+	server := grpc.NewServer()
+	err = graphDB.RegisterService(server, func(name, token string) bool {
+		return token == conformanceToken
+	})
+	if err != nil {
+		log.Fatalf("cannot register backend %s: %s", *backendName, err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	err = backendtest.TestBackend(graphDB, backendtest.Options{Examples: *examples, Matcher: matchAlgo, Token: conformanceToken})
+	if err != nil {
+		log.Fatalf("❌️ backend %s is not conformant:\n%s", *backendName, err)
+	}
+	log.Printf("✅️ backend %s is conformant", *backendName)
+}
@@ -27,6 +27,11 @@ func (c *Certificate) IsEmpty() bool {
 	return c.caCertFile == "" && c.certFile == "" && c.keyFile == ""
 }
 
+// Files returns the ca certificate, certificate, and key files
+func (c *Certificate) Files() (string, string, string) {
+	return c.caCertFile, c.certFile, c.keyFile
+}
+
 // Validate all fields are defined
 func (c *Certificate) Validate() error {
 	if c.IsEmpty() {
@@ -22,6 +22,7 @@ type RainbowClient struct {
 	host       string
 	connection *grpc.ClientConn
 	service    pb.RainbowSchedulerClient
+	cert       *certs.Certificate
 }
 
 var _ Client = (*RainbowClient)(nil)
@@ -41,7 +42,7 @@ type Client interface {
 
 	// Job Client Interactions
 	AcceptJobs(ctx context.Context, cluster, secret string, jobids []int32) (*pb.AcceptJobsResponse, error)
-	SubmitJob(ctx context.Context, job *js.Jobspec, cfg *config.RainbowConfig) (*pb.SubmitJobResponse, error)
+	SubmitJob(ctx context.Context, job *js.Jobspec, cfg *config.RainbowConfig, explain bool) (*pb.SubmitJobResponse, error)
 	ReceiveJobs(ctx context.Context, cluster, token string, maxJobs int32) (*pb.ReceiveJobsResponse, error)
 }
 
@@ -52,7 +53,7 @@ func NewClient(host string, cert *certs.Certificate) (Client, error) {
 	}
 
 	log.Printf("🌈️ starting client (%s)...", host)
-	c := &RainbowClient{host: host}
+	c := &RainbowClient{host: host, cert: cert}
 
 	// The context allows us to control the timeout
 	ctx, cancel := context.WithTimeout(context.TODO(), defaultTimeout)
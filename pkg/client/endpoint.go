@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -12,6 +13,8 @@ import (
 	"github.com/converged-computing/rainbow/pkg/graph"
 	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
 	"github.com/converged-computing/rainbow/pkg/graph/backend"
+	rspec "github.com/converged-computing/rainbow/pkg/jobspec"
+	"github.com/converged-computing/rainbow/pkg/types"
 	"github.com/converged-computing/rainbow/pkg/utils"
 	"github.com/pkg/errors"
 
@@ -25,10 +28,12 @@ type RegisterRequest struct {
 
 // SubmitJob submits a job to a named cluster.
 // The token specific to the cluster is required
+// If explain is true, we show why clusters did not match
 func (c *RainbowClient) SubmitJob(
 	ctx context.Context,
 	job *js.Jobspec,
 	cfg *config.RainbowConfig,
+	explain bool,
 ) (*pb.SubmitJobResponse, error) {
 
 	response := &pb.SubmitJobResponse{}
@@ -44,29 +49,64 @@ func (c *RainbowClient) SubmitJob(
 	if err != nil {
 		return response, err
 	}
+	// The options tell us how to reach (and authenticate with) the graph
+	// Unless they say otherwise, the graph uses the same TLS as rainbow
+	options := map[string]string{}
+	for key, value := range cfg.GraphDatabase.Options {
+		options[key] = value
+	}
+	if !c.cert.IsEmpty() && options["caCert"] == "" {
+		options["caCert"], options["cert"], options["key"] = c.cert.Files()
+	}
+	err = graphDB.Init(options)
+	if err != nil {
+		return response, err
+	}
+
+	// Prepare the subsystem match algorithm, with options from the config
+	// The options are sent with the request to the graph that does the match.
+	matchAlgo, err := algorithm.New(cfg.Scheduler.Algorithms.Match.Name, cfg.Scheduler.Algorithms.Match.Options)
+	if err != nil {
+		return response, err
+	}
+
+	// The graph (and the cluster that gets the job) cannot read our files,
+	// so anything the jobspec references for the matcher is added to it
+	err = algorithm.PrepareJobspec(matchAlgo, job)
+	if err != nil {
+		return response, err
+	}
 
-	// Prepare the subsystem match algorithm
-	matchAlgo, err := algorithm.Get(cfg.Scheduler.Algorithms.Match.Name)
+	// Malformed requirements (and count ranges) are an error instead of never matching
+	err = rspec.ValidateRanges(job)
 	if err != nil {
-		log.Fatal(err)
+		return response, fmt.Errorf("jobspec count ranges are not valid: %w", err)
+	}
+	validator, ok := matchAlgo.(algorithm.JobspecValidator)
+	if ok {
+		err = validator.ValidateJobspec(job)
+		if err != nil {
+			return response, fmt.Errorf("jobspec requirements are not valid: %w", err)
+		}
 	}
-	matchAlgo.Init(cfg.Scheduler.Algorithms.Match.Options)
 
-	// TODO we need to have a check here to see what clusters
-	// the user has permission to do. Either that can be represented in
-	// the graph database (and the call goes directly to it) or it
-	// is checked first in rainbow, and still enforced in the graph
-	// (but we limit our search). Likely the first is preferable.
-	// Ask the graphDB if the jobspec can be satisfied
+	// Ask the graphDB if the jobspec can be satisfied. Our cluster tokens
+	// are the credentials, and the graph only searches the clusters we can use.
 	// TODO what does a match look like?
-	matches, err := graphDB.Satisfies(job, matchAlgo)
+	result, err := graphDB.Satisfies(job, matchAlgo, explain, cfg.GetClusterTokens())
 	if err != nil {
 		return response, err
 	}
+	if explain {
+		showExplanations(result)
+	}
+	matches := result.Clusters
 
 	// Cut out early (without contacting rainbow) if there are no matches
 	if len(matches) > 0 {
 		log.Printf("🎯️ We found %d matches! %s\b", len(matches), matches)
+		showScores(result)
+		showSizes(result)
 	} else {
 		return response, fmt.Errorf("😥️ There were no matches for this job")
 	}
@@ -75,14 +115,30 @@ func (c *RainbowClient) SubmitJob(
 	defer cancel()
 
 	// Prepare clusters for submit jobs request
-	clusters := make([]*pb.SubmitJobRequest_Cluster, len(cfg.Clusters))
+	clusters := []*pb.SubmitJobRequest_Cluster{}
 
 	// Take an intersection of clusters and matches
 	// A token will not be returned if we do not know about the cluster
-	for i, match := range matches {
+	// Each cluster carries the placement from satisfy, if we have one,
+	// the score for preferences, and the sizes for count ranges, if the
+	// jobspec has them
+	for _, match := range matches {
 		creds := cfg.GetClusterToken(match)
 		if creds != "" {
-			clusters[i] = &pb.SubmitJobRequest_Cluster{Token: creds, Name: match}
+			placement, err := serializePlacement(result.Placements[match])
+			if err != nil {
+				return response, err
+			}
+			sizes, err := serializeSizes(result.Sizes[match])
+			if err != nil {
+				return response, err
+			}
+			cluster := &pb.SubmitJobRequest_Cluster{Token: creds, Name: match, Placement: placement, Sizes: sizes}
+			score, ok := result.Scores[match]
+			if ok {
+				cluster.MatchScore = &score
+			}
+			clusters = append(clusters, cluster)
 		}
 	}
 
@@ -104,6 +160,30 @@ func (c *RainbowClient) SubmitJob(
 	return response, err
 }
 
+// serializePlacement converts slot placements to json, empty if there are none
+func serializePlacement(slots []types.SlotPlacement) (string, error) {
+	if len(slots) == 0 {
+		return "", nil
+	}
+	out, err := json.Marshal(slots)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// serializeSizes converts sizes for count ranges to json, empty if there are none
+func serializeSizes(sizes map[string]int32) (string, error) {
+	if len(sizes) == 0 {
+		return "", nil
+	}
+	out, err := json.Marshal(sizes)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
 // ReceiveJobs (request them) for a specific clusters
 func (c *RainbowClient) ReceiveJobs(
 	ctx context.Context,
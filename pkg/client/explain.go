@@ -0,0 +1,80 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// showExplanations prints a table of reasons clusters did not match
+func showExplanations(result *types.SatisfyResult) {
+	if len(result.Mismatches) == 0 {
+		fmt.Println("🔎️ There are no mismatched clusters to explain")
+		return
+	}
+	fmt.Println("🔎️ Clusters that cannot satisfy this job:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tREASON\tRESOURCE\tNEEDED\tFOUND\tUNMET")
+	for _, cluster := range result.ClusterNames() {
+		for _, explanation := range result.Mismatches[cluster] {
+			unmet := strings.Join(explanation.Unmet, ", ")
+			if unmet == "" {
+				unmet = "-"
+			}
+			fmt.Fprintf(
+				w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+				cluster,
+				explanation.Reason,
+				explanation.Resource,
+				explanation.Needed,
+				explanation.Found,
+				unmet,
+			)
+		}
+	}
+	w.Flush()
+}
+
+// showScores prints the fraction of preferences each match satisfies
+func showScores(result *types.SatisfyResult) {
+	if len(result.Scores) == 0 {
+		return
+	}
+	clusters := append([]string{}, result.Clusters...)
+	sort.Strings(clusters)
+	fmt.Println("⭐️ Preferences satisfied by matching clusters:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tSCORE")
+	for _, cluster := range clusters {
+		fmt.Fprintf(w, "%s\t%.2f\n", cluster, result.Scores[cluster])
+	}
+	w.Flush()
+}
+
+// showSizes prints the size chosen for each count range, by matching cluster
+func showSizes(result *types.SatisfyResult) {
+	if len(result.Sizes) == 0 {
+		return
+	}
+	clusters := append([]string{}, result.Clusters...)
+	sort.Strings(clusters)
+	fmt.Println("🫠️ Sizes for count ranges of matching clusters:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tRESOURCE\tSIZE")
+	for _, cluster := range clusters {
+		sizes := result.Sizes[cluster]
+		paths := make([]string, 0, len(sizes))
+		for path := range sizes {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", cluster, path, sizes[path])
+		}
+	}
+	w.Flush()
+}
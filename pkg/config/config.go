@@ -103,6 +103,15 @@ func (c *RainbowConfig) ToJson() (string, error) {
 	return string(out), nil
 }
 
+// GetClusterTokens returns a lookup of cluster names to tokens
+func (c *RainbowConfig) GetClusterTokens() map[string]string {
+	tokens := map[string]string{}
+	for _, cluster := range c.Clusters {
+		tokens[cluster.Name] = cluster.Token
+	}
+	return tokens
+}
+
 // GetCluster returns a cluster, if it is known to the config
 func (c *RainbowConfig) GetClusterToken(clusterName string) string {
 	for _, c := range c.Clusters {
@@ -9,6 +9,7 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"strings"
 )
 
 type Database struct {
@@ -166,6 +167,8 @@ func (db *Database) createTables() error {
 		  cluster TEXT,
 		  name TEXT,
 		  jobspec string,
+		  placement TEXT,
+		  sizes TEXT,
 		  FOREIGN KEY(cluster) REFERENCES clusters(name)
 		);`
 
@@ -182,6 +185,52 @@ func (db *Database) createTables() error {
 	return nil
 }
 
+// Columns added to the jobs table after it was first created. A database
+// created before a column was added is migrated when it is opened.
+var jobsMigrations = []string{
+	"placement TEXT",
+	"sizes TEXT",
+}
+
+// migrate adds the columns that the jobs table is missing
+func (db *Database) migrate() error {
+
+	conn, err := db.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query("SELECT name FROM pragma_table_info('jobs')")
+	if err != nil {
+		return err
+	}
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+
+	for _, column := range jobsMigrations {
+		name := strings.Fields(column)[0]
+		if columns[name] {
+			continue
+		}
+		log.Printf("   🏓️ adding column %s to jobs...", name)
+		_, err = conn.Exec(fmt.Sprintf("ALTER TABLE jobs ADD COLUMN %s", column))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func InitDatabase(filepath string, cleanup bool) (*Database, error) {
 
 	// Create a new database (todo, add cleanupc check)
@@ -207,5 +256,9 @@ func InitDatabase(filepath string, cleanup bool) (*Database, error) {
 			return nil, err
 		}
 	}
+	err = db.migrate()
+	if err != nil {
+		return nil, err
+	}
 	return &db, err
 }
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -15,6 +16,12 @@ type Job struct {
 	Name    string `json:"name"`
 	Jobspec string `json:"jobspec"`
 	Command string `json:"command"`
+
+	// Serialized slot placements from satisfy, a hint for the cluster
+	Placement string `json:"placement,omitempty"`
+
+	// Serialized sizes chosen for count ranges, what the cluster was given
+	Sizes string `json:"sizes,omitempty"`
 }
 
 // ToJson converts the job to json for sending back!
@@ -36,32 +43,37 @@ func (db *Database) addJob(job *pb.SubmitJobRequest, cluster string) (*Job, erro
 	}
 	defer conn.Close()
 
+	// The placement and sizes are only for the cluster the job is assigned to
+	placement := ""
+	sizes := ""
+	for _, contender := range job.Clusters {
+		if contender != nil && contender.Name == cluster {
+			placement = contender.Placement
+			sizes = contender.Sizes
+		}
+	}
+
 	// The jobspec is added once to the database, first without assignment
-	fields := "(name, cluster, jobspec)"
-	values := fmt.Sprintf("(\"%s\", \"%s\", \"%s\")", job.Name, cluster, job.Jobspec)
+	// The placement and sizes are json, so we provide values as arguments
+	fields := "(name, cluster, jobspec, placement, sizes)"
+	query := fmt.Sprintf("INSERT into jobs %s VALUES (?, ?, ?, ?, ?)", fields)
 
 	// Submit the query to get the global id (jobid, not submit yet)
-	query := fmt.Sprintf("INSERT into jobs %s VALUES %s", fields, values)
-
-	// Since we want to get a result back, we use query
-	statement, err := conn.Prepare(query)
+	result, err := conn.Exec(query, job.Name, cluster, job.Jobspec, placement, sizes)
 	if err != nil {
 		return &j, err
 	}
-	defer statement.Close()
-
-	// We expect only one job
-	rows, err := statement.Query()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return &j, err
 	}
-
-	// Unwrap into job
-	for rows.Next() {
-		err := rows.Scan(&j.Id, &j.Cluster, &j.Name, &j.Jobspec)
-		if err != nil {
-			return &j, err
-		}
+	j = Job{
+		Id:        int32(id),
+		Cluster:   cluster,
+		Name:      job.Name,
+		Jobspec:   job.Jobspec,
+		Placement: placement,
+		Sizes:     sizes,
 	}
 	return &j, nil
 }
@@ -103,7 +115,8 @@ func (db *Database) ReceiveJobs(
 	defer conn.Close()
 
 	// If the max jobs is < 1, we are asking to see all jobs
-	query := fmt.Sprintf("SELECT * FROM jobs WHERE cluster = '%s'", cluster.Name)
+	// Columns are named so the scan below does not depend on their order
+	query := "SELECT idJob, cluster, name, jobspec, placement, sizes FROM jobs WHERE cluster = ?"
 	if request.MaxJobs >= 1 {
 		query = fmt.Sprintf("%s LIMIT %d", query, request.MaxJobs)
 	}
@@ -116,22 +129,26 @@ func (db *Database) ReceiveJobs(
 	defer statement.Close()
 
 	// We expect only one job
-	rows, err := statement.Query()
+	rows, err := statement.Query(cluster.Name)
 	if err != nil {
 		return response, err
 	}
+	defer rows.Close()
 
 	// Failures from here until end are error
 	response.Status = pb.ReceiveJobsResponse_REQUEST_JOBS_ERROR
 
 	// Unwrap into list of jobs
 	jobs := map[int32]string{}
-	var j Job
 	for rows.Next() {
-		err := rows.Scan(&j.Id, &j.Cluster, &j.Name, &j.Jobspec)
+		var j Job
+		var placement, sizes sql.NullString
+		err := rows.Scan(&j.Id, &j.Cluster, &j.Name, &j.Jobspec, &placement, &sizes)
 		if err != nil {
 			return response, err
 		}
+		j.Placement = placement.String
+		j.Sizes = sizes.String
 		jobstr, err := j.ToJson()
 		if err != nil {
 			return response, err
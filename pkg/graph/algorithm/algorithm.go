@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
 	"github.com/converged-computing/rainbow/pkg/types"
 )
 
@@ -22,10 +23,47 @@ type MatchAlgorithm interface {
 	Init(map[string]string) error
 
 	// A MatchAlgorithm needs to take a slot and determine if it matches
-	CheckSubsystemEdge(slotNeeds *types.MatchAlgorithmNeeds, edge *types.Edge)
+	// The needs that the edge satisfies are updated in place.
+	CheckSubsystemEdge(slotNeeds types.MatchAlgorithmNeeds, edge *types.Edge)
 
 	// Graph backends that support cypher need a cypher query for the algorithm
-	GenerateCypher(matchNeeds *types.MatchAlgorithmNeeds) string
+	// The resource is the variable of the resource node in the query.
+	GenerateCypher(resource string, matchNeeds types.MatchAlgorithmNeeds) string
+}
+
+// A JobspecValidator is a MatchAlgorithm that can check the requirements
+// of a jobspec are well formed before it is submit
+type JobspecValidator interface {
+	ValidateJobspec(jobspec *js.Jobspec) error
+}
+
+// A ConfigurableAlgorithm is a MatchAlgorithm with options. The same
+// algorithm can be asked for with different options (e.g., by clients of
+// one graph), so it makes a new matcher for each set of options.
+type ConfigurableAlgorithm interface {
+	WithOptions(options map[string]string) (MatchAlgorithm, error)
+
+	// Options returns the options of the matcher, to send with a request
+	Options() map[string]string
+}
+
+// A JobspecAlgorithm is a MatchAlgorithm that reads the jobspec itself
+// (e.g., an artifact its attributes reference) to decide what to match.
+type JobspecAlgorithm interface {
+
+	// PrepareJobspec adds what the jobspec references (e.g., a local file)
+	// to the jobspec, so the graph that does the match does not need it
+	PrepareJobspec(jobspec *js.Jobspec) error
+
+	// ForJobspec returns a matcher for the jobspec
+	ForJobspec(jobspec *js.Jobspec) (MatchAlgorithm, error)
+}
+
+// A NeedsAlgorithm is a MatchAlgorithm that has needs for a resource of the
+// jobspec in addition to its requires entries. Needs are by subsystem, and
+// new needs are returned for each call, since they hold what is satisfied.
+type NeedsAlgorithm interface {
+	ResourceNeeds(resource *js.Resource) types.MatchAlgorithmNeeds
 }
 
 // List returns known algorithms
@@ -51,6 +89,47 @@ func Get(name string) (MatchAlgorithm, error) {
 	return nil, fmt.Errorf("did not find algorithm named %s", name)
 }
 
+// New gets an algorithm by name, and a matcher with options if it has any
+func New(name string, options map[string]string) (MatchAlgorithm, error) {
+	algorithm, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	configurable, ok := algorithm.(ConfigurableAlgorithm)
+	if ok {
+		return configurable.WithOptions(options)
+	}
+	return algorithm, algorithm.Init(options)
+}
+
+// Options returns the options of a matcher, if it has any
+func Options(algorithm MatchAlgorithm) map[string]string {
+	configurable, ok := algorithm.(ConfigurableAlgorithm)
+	if !ok {
+		return map[string]string{}
+	}
+	return configurable.Options()
+}
+
+// PrepareJobspec prepares a jobspec for a matcher, if it reads the jobspec
+func PrepareJobspec(algorithm MatchAlgorithm, jobspec *js.Jobspec) error {
+	reader, ok := algorithm.(JobspecAlgorithm)
+	if !ok {
+		return nil
+	}
+	return reader.PrepareJobspec(jobspec)
+}
+
+// ForJobspec returns a matcher for a jobspec, the same matcher if it
+// does not read the jobspec
+func ForJobspec(algorithm MatchAlgorithm, jobspec *js.Jobspec) (MatchAlgorithm, error) {
+	reader, ok := algorithm.(JobspecAlgorithm)
+	if !ok {
+		return algorithm, nil
+	}
+	return reader.ForJobspec(jobspec)
+}
+
 // GetOrFail ensures we can find the entry
 func GetOrFail(name string) MatchAlgorithm {
 	algorithm, err := Get(name)
@@ -17,10 +17,16 @@ var (
 	Backends map[string]GraphBackend
 )
 
+// A ClusterValidator checks that a token is valid for a cluster.
+// Rainbow provides it to backends that serve their own grpc service,
+// so they can authorize requests that carry cluster tokens.
+type ClusterValidator func(cluster, token string) bool
+
 // A Graph backend is an interface to hold rainbow clusters
 // Each backend should be able to handle basic queries to request work.
 //
 //	Satisfies: find clusters where the work can be run
+//	Capacity: how many copies of the work each cluster can run
 //
 // We will add more endpoints as they make sense. For example, rainbow does
 // not control the actual scheduling, so it cannot reserve nodes or update
@@ -32,10 +38,17 @@ type GraphBackend interface {
 	Init(map[string]string) error
 
 	// Determine if a jobspec can be satified in the graph
-	Satisfies(*js.Jobspec, algorithm.MatchAlgorithm) ([]string, error)
+	// If explain is true, the result includes reasons for mismatches
+	// The lookup of cluster names to tokens are the caller's credentials
+	Satisfies(*js.Jobspec, algorithm.MatchAlgorithm, bool, map[string]string) (*types.SatisfyResult, error)
+
+	// Determine how many copies of a jobspec each named cluster can host
+	// Backends that cannot count return an empty lookup
+	Capacity(*js.Jobspec, algorithm.MatchAlgorithm, []string) (map[string]int32, error)
 
 	// Register an additional grpc server
-	RegisterService(*grpc.Server) error
+	// The validator checks cluster tokens for requests to that server
+	RegisterService(*grpc.Server, ClusterValidator) error
 
 	// Add nodes for a newly registered cluster
 	AddCluster(name string, nodes *graph.JsonGraph, subsystem string) error
@@ -0,0 +1,372 @@
+package backendtest
+
+// Conformance checks that a graph backend behaves like the others.
+// Any backend can run them (see hack/conformance), and a backend that passes
+// gives the same results as the memory backend for the same requests.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Options for the conformance checks
+type Options struct {
+
+	// The directory with the examples, docs/examples in the repository
+	Examples string
+
+	// The match algorithm to satisfy with
+	Matcher algorithm.MatchAlgorithm
+
+	// Prefix for the names of clusters that are registered (and deleted)
+	Prefix string
+
+	// Token sent for each cluster with a satisfy request
+	Token string
+}
+
+// A suite holds the backend and the failures that were found
+type suite struct {
+	graph    backend.GraphBackend
+	options  Options
+	failures []error
+}
+
+// TestBackend registers clusters from the examples, and checks that
+// registering, subsystems, state, satisfy, and deletion behave as expected.
+// The backend must already be initialized (and its service registered, if
+// it has one). All failures are returned together, or nil if there are none.
+func TestBackend(graphDB backend.GraphBackend, options Options) error {
+	if options.Prefix == "" {
+		options.Prefix = "backendtest"
+	}
+	if options.Token == "" {
+		options.Token = "backendtest"
+	}
+	if options.Matcher == nil {
+		return fmt.Errorf("a match algorithm is required")
+	}
+	s := suite{graph: graphDB, options: options}
+
+	// The scheduler example has an io subsystem, the range example
+	// a spack subsystem with versions, and the self example has
+	// nodes and cores with their own metadata (and a spack subsystem
+	// with packages in environments, from the path example)
+	keebler := options.Prefix + "-keebler"
+	spack := options.Prefix + "-spack"
+	self := options.Prefix + "-self"
+	unknown := options.Prefix + "-unknown"
+
+	s.testRegister(keebler, spack, self, unknown)
+	s.testSubsystem(keebler, unknown)
+	s.testState(keebler, unknown)
+	s.testSatisfy(keebler, spack, self)
+	s.testDelete(keebler, spack, self)
+	return errors.Join(s.failures...)
+}
+
+// testRegister adds clusters, and checks a cluster cannot be added twice
+func (s *suite) testRegister(keebler, spack, self, unknown string) {
+	nodes := s.readNodes("scheduler", "cluster-nodes.json")
+	s.expect("register cluster", s.graph.AddCluster(keebler, nodes, ""), false)
+	s.expect("register cluster again", s.graph.AddCluster(keebler, nodes, ""), true)
+
+	// Without the io subsystem, a request for it cannot be satisfied
+	s.satisfy("satisfy before subsystem", "scheduler/jobspec-io.yaml", []string{keebler}, []string{})
+
+	// A subsystem needs a cluster
+	subsystem := s.readNodes("scheduler", "cluster-io-subsystem.json")
+	s.expect("register subsystem for unknown cluster", s.graph.AddSubsystem(unknown, subsystem, "io"), true)
+
+	nodes = s.readNodes("match-algorithms/range", "cluster-nodes.json")
+	s.expect("register second cluster", s.graph.AddCluster(spack, nodes, ""), false)
+	subsystem = s.readNodes("match-algorithms/range", "spack-subsystem.json")
+	s.expect("register spack subsystem", s.graph.AddSubsystem(spack, subsystem, "spack"), false)
+
+	nodes = s.readNodes("match-algorithms/self", "cluster-nodes.json")
+	s.expect("register third cluster", s.graph.AddCluster(self, nodes, ""), false)
+	subsystem = s.readNodes("match-algorithms/path", "spack-subsystem.json")
+	s.expect("register spack environments", s.graph.AddSubsystem(self, subsystem, "spack"), false)
+}
+
+// testSubsystem adds a subsystem, and checks it cannot be added twice
+func (s *suite) testSubsystem(keebler, unknown string) {
+	subsystem := s.readNodes("scheduler", "cluster-io-subsystem.json")
+	s.expect("register subsystem", s.graph.AddSubsystem(keebler, subsystem, "io"), false)
+	s.expect("register subsystem again", s.graph.AddSubsystem(keebler, subsystem, "io"), true)
+	s.expect("delete subsystem for unknown cluster", s.graph.DeleteSubsystem(unknown, "io"), true)
+}
+
+// testState updates state, and checks new values are merged with old ones
+func (s *suite) testState(keebler, unknown string) {
+	payload, err := os.ReadFile(filepath.Join(s.options.Examples, "scheduler", "cluster-state.json"))
+	if err != nil {
+		s.fail("read cluster state: %s", err)
+		return
+	}
+	s.expect("update state", s.graph.UpdateState(keebler, string(payload)), false)
+	s.expect("update state again", s.graph.UpdateState(keebler, `{"nodes_free": 10, "queue": "normal"}`), false)
+	s.expect("update state for unknown cluster", s.graph.UpdateState(unknown, `{"nodes_free": 10}`), true)
+
+	expected := types.ClusterState{}
+	err = json.Unmarshal(payload, &expected)
+	if err != nil {
+		s.fail("parse cluster state: %s", err)
+		return
+	}
+	expected["nodes_free"] = 10
+	expected["queue"] = "normal"
+
+	states, err := s.graph.GetStates([]string{keebler})
+	s.expect("get states", err, false)
+	if err == nil && !sameJson(states[keebler], expected) {
+		s.fail("get states: expected %v, got %v", expected, states[keebler])
+	}
+	_, err = s.graph.GetStates([]string{keebler, unknown})
+	s.expect("get states for unknown cluster", err, true)
+}
+
+// testSatisfy checks that clusters match the example jobspecs, and that
+// only the clusters in a request are searched
+func (s *suite) testSatisfy(keebler, spack, self string) {
+	both := []string{keebler, spack}
+	all := []string{keebler, spack, self}
+	s.satisfy("satisfy io", "scheduler/jobspec-io.yaml", both, []string{keebler})
+	s.satisfy("satisfy constraint", "scheduler/jobspec-constraint.yaml", both, both)
+	s.satisfy("satisfy valid range", "match-algorithms/range/jobspec-valid-range.yaml", both, []string{spack})
+	s.satisfy("satisfy invalid range", "match-algorithms/range/jobspec-invalid-range.yaml", both, []string{})
+	s.satisfy("satisfy valid numeric", "match-algorithms/numeric/jobspec-valid-numeric.yaml", both, []string{keebler})
+	s.satisfy("satisfy invalid numeric", "match-algorithms/numeric/jobspec-invalid-numeric.yaml", both, []string{})
+	s.satisfy("satisfy valid set", "match-algorithms/set/jobspec-valid-set.yaml", both, []string{keebler})
+	s.satisfy("satisfy invalid set", "match-algorithms/set/jobspec-invalid-set.yaml", both, []string{})
+	s.satisfy("satisfy valid self", "match-algorithms/self/jobspec-valid-self.yaml", all, []string{self})
+	s.satisfy("satisfy invalid self", "match-algorithms/self/jobspec-invalid-self.yaml", all, []string{})
+	s.satisfy("satisfy valid path", "match-algorithms/path/jobspec-valid-path.yaml", all, []string{self})
+	s.satisfy("satisfy invalid path", "match-algorithms/path/jobspec-invalid-path.yaml", all, []string{})
+	s.satisfy("satisfy range past single digits", "match-algorithms/range/jobspec-valid-range-digits.yaml", both, []string{spack})
+	s.satisfy("satisfy range above single digits", "match-algorithms/range/jobspec-invalid-range-digits.yaml", both, []string{})
+	s.satisfy("satisfy range from a prerelease", "match-algorithms/range/jobspec-valid-range-prerelease.yaml", both, []string{spack})
+	s.satisfy("satisfy range to a prerelease", "match-algorithms/range/jobspec-invalid-range-prerelease.yaml", both, []string{})
+	s.satisfy("satisfy valid range for one cluster", "match-algorithms/range/jobspec-valid-range.yaml", []string{keebler}, []string{})
+	s.score("score preferences", "match-algorithms/prefer/jobspec-prefer.yaml", all, map[string]float64{keebler: 2.0 / 3, spack: 1.0 / 3, self: 0})
+	s.score("score preferences with requirements", "match-algorithms/prefer/jobspec-prefer-required.yaml", all, map[string]float64{keebler: 0.5})
+	s.score("score without preferences", "scheduler/jobspec-io.yaml", all, map[string]float64{})
+	s.sizes("sizes for node range", "slots/jobspec-range-nodes.yaml", []string{self}, map[string]map[string]int32{self: {"ranks": 3}})
+	s.sizes("sizes for preferred range", "slots/jobspec-range-preferred.yaml", []string{self}, map[string]map[string]int32{self: {"ranks": 2}})
+	s.satisfy("satisfy range too big", "slots/jobspec-range-too-big.yaml", []string{self}, []string{})
+	s.capacity("capacity valid range", "match-algorithms/range/jobspec-valid-range.yaml", both, map[string]bool{keebler: false, spack: true})
+	s.capacity("capacity valid self", "match-algorithms/self/jobspec-valid-self.yaml", all, map[string]bool{self: true})
+
+	// Options for the matcher are sent with the request
+	ignoreCase := map[string]string{"ignore_case": "true"}
+	ignoreMissing := map[string]string{"missing_field": "ignore"}
+	strict := map[string]string{"semver": "strict"}
+	s.satisfy("satisfy with case", "match-algorithms/options/jobspec-ignore-case.yaml", both, []string{})
+	s.satisfyWith(ignoreCase, "satisfy ignoring case", "match-algorithms/options/jobspec-ignore-case.yaml", both, []string{keebler})
+	s.satisfy("satisfy missing field", "match-algorithms/options/jobspec-missing-field.yaml", both, []string{})
+	s.satisfyWith(ignoreMissing, "satisfy ignoring missing field", "match-algorithms/options/jobspec-missing-field.yaml", both, []string{keebler})
+	s.satisfy("satisfy loose version", "match-algorithms/options/jobspec-loose-version.yaml", both, []string{spack})
+	s.satisfyWith(strict, "satisfy strict version", "match-algorithms/options/jobspec-loose-version.yaml", both, []string{})
+	s.satisfyWith(strict, "satisfy valid range strictly", "match-algorithms/range/jobspec-valid-range.yaml", both, []string{spack})
+
+	// The compatibility algorithm (if it is known) matches node metadata
+	// to an artifact in the jobspec
+	compatibility, err := algorithm.New("compatibility", map[string]string{})
+	if err != nil {
+		return
+	}
+	s.satisfyMatcher(compatibility, "satisfy valid compatibility", "match-algorithms/compatibility/jobspec-valid-compatibility.yaml", all, []string{self})
+	s.satisfyMatcher(compatibility, "satisfy invalid compatibility", "match-algorithms/compatibility/jobspec-invalid-compatibility.yaml", all, []string{})
+
+	// An artifact is only read from a path by the client, never the graph
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(s.options.Examples, "match-algorithms/compatibility/jobspec-path-compatibility.yaml"))
+	if err != nil {
+		s.fail("satisfy compatibility path: %s", err)
+		return
+	}
+	_, err = s.graph.Satisfies(jobspec, compatibility, false, map[string]string{self: s.options.Token})
+	s.expect("satisfy compatibility path", err, true)
+}
+
+// testDelete deletes subsystems and clusters, and checks they are gone
+func (s *suite) testDelete(keebler, spack, self string) {
+	s.expect("delete subsystem", s.graph.DeleteSubsystem(keebler, "io"), false)
+	s.expect("delete subsystem again", s.graph.DeleteSubsystem(keebler, "io"), true)
+	s.satisfy("satisfy after delete subsystem", "scheduler/jobspec-io.yaml", []string{keebler}, []string{})
+
+	// Deleting a cluster deletes all of its subsystems
+	s.expect("delete cluster", s.graph.DeleteCluster(spack), false)
+	s.expect("delete cluster again", s.graph.DeleteCluster(spack), true)
+	_, err := s.graph.GetStates([]string{spack})
+	s.expect("get states for deleted cluster", err, true)
+	s.satisfy("satisfy after delete cluster", "scheduler/jobspec-constraint.yaml", []string{keebler, spack}, []string{keebler})
+
+	// The cluster can be registered again, and it has no subsystem
+	nodes := s.readNodes("match-algorithms/range", "cluster-nodes.json")
+	s.expect("register deleted cluster", s.graph.AddCluster(spack, nodes, ""), false)
+	s.satisfy("satisfy registered again", "match-algorithms/range/jobspec-valid-range.yaml", []string{spack}, []string{})
+
+	s.expect("delete cluster", s.graph.DeleteCluster(spack), false)
+	s.expect("delete third cluster", s.graph.DeleteCluster(self), false)
+	s.expect("delete last cluster", s.graph.DeleteCluster(keebler), false)
+}
+
+// satisfy asks the backend to satisfy a jobspec for some clusters, and
+// checks the clusters that match
+func (s *suite) satisfy(name, jobspecPath string, clusters, expected []string) {
+	s.satisfyMatcher(s.options.Matcher, name, jobspecPath, clusters, expected)
+}
+
+// satisfyWith is satisfy with a matcher that has options
+func (s *suite) satisfyWith(options map[string]string, name, jobspecPath string, clusters, expected []string) {
+	matcher, err := algorithm.New(s.options.Matcher.Name(), options)
+	if err != nil {
+		s.fail("%s: %s", name, err)
+		return
+	}
+	s.satisfyMatcher(matcher, name, jobspecPath, clusters, expected)
+}
+
+func (s *suite) satisfyMatcher(
+	matcher algorithm.MatchAlgorithm,
+	name, jobspecPath string,
+	clusters, expected []string,
+) {
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(s.options.Examples, jobspecPath))
+	if err != nil {
+		s.fail("%s: load %s: %s", name, jobspecPath, err)
+		return
+	}
+	tokens := map[string]string{}
+	for _, cluster := range clusters {
+		tokens[cluster] = s.options.Token
+	}
+	result, err := s.graph.Satisfies(jobspec, matcher, false, tokens)
+	if err != nil {
+		s.fail("%s: %s", name, err)
+		return
+	}
+	matches := append([]string{}, result.Clusters...)
+	sort.Strings(matches)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(matches, expected) {
+		s.fail("%s: expected matches %v, got %v", name, expected, matches)
+	}
+}
+
+// score checks the fraction of the preferences of a jobspec that each
+// matching cluster satisfies
+func (s *suite) score(name, jobspecPath string, clusters []string, expected map[string]float64) {
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(s.options.Examples, jobspecPath))
+	if err != nil {
+		s.fail("%s: load %s: %s", name, jobspecPath, err)
+		return
+	}
+	tokens := map[string]string{}
+	for _, cluster := range clusters {
+		tokens[cluster] = s.options.Token
+	}
+	result, err := s.graph.Satisfies(jobspec, s.options.Matcher, false, tokens)
+	if err != nil {
+		s.fail("%s: %s", name, err)
+		return
+	}
+	if len(result.Scores) != len(expected) {
+		s.fail("%s: expected scores %v, got %v", name, expected, result.Scores)
+		return
+	}
+	for cluster, score := range expected {
+		found, ok := result.Scores[cluster]
+		if !ok || math.Abs(found-score) > 1e-9 {
+			s.fail("%s: expected cluster %s to score %.2f, got %.2f", name, cluster, score, found)
+		}
+	}
+}
+
+// sizes checks the size chosen for each count range of a jobspec, by
+// cluster that matches
+func (s *suite) sizes(name, jobspecPath string, clusters []string, expected map[string]map[string]int32) {
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(s.options.Examples, jobspecPath))
+	if err != nil {
+		s.fail("%s: load %s: %s", name, jobspecPath, err)
+		return
+	}
+	tokens := map[string]string{}
+	for _, cluster := range clusters {
+		tokens[cluster] = s.options.Token
+	}
+	result, err := s.graph.Satisfies(jobspec, s.options.Matcher, false, tokens)
+	if err != nil {
+		s.fail("%s: %s", name, err)
+		return
+	}
+	if !reflect.DeepEqual(result.Sizes, expected) {
+		s.fail("%s: expected sizes %v, got %v", name, expected, result.Sizes)
+	}
+}
+
+// capacity checks that clusters that can host a jobspec have capacity,
+// and those that cannot have none. A backend that cannot count returns
+// an empty lookup, which is allowed.
+func (s *suite) capacity(name, jobspecPath string, clusters []string, expected map[string]bool) {
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(s.options.Examples, jobspecPath))
+	if err != nil {
+		s.fail("%s: load %s: %s", name, jobspecPath, err)
+		return
+	}
+	capacity, err := s.graph.Capacity(jobspec, s.options.Matcher, clusters)
+	if err != nil {
+		s.fail("%s: %s", name, err)
+		return
+	}
+	if len(capacity) == 0 {
+		return
+	}
+	for _, cluster := range clusters {
+		if (capacity[cluster] > 0) != expected[cluster] {
+			s.fail("%s: cluster %s has capacity %d", name, cluster, capacity[cluster])
+		}
+	}
+}
+
+// readNodes reads a JGF graph from the examples
+func (s *suite) readNodes(example, filename string) *jgf.JsonGraph {
+	nodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(s.options.Examples, example, filename))
+	if err != nil {
+		s.fail("read %s/%s: %s", example, filename, err)
+	}
+	return &nodes
+}
+
+// expect records a failure if an error is (or is not) returned
+func (s *suite) expect(name string, err error, wantError bool) {
+	if wantError && err == nil {
+		s.fail("%s: expected an error", name)
+	} else if !wantError && err != nil {
+		s.fail("%s: %s", name, err)
+	}
+}
+
+func (s *suite) fail(format string, args ...any) {
+	s.failures = append(s.failures, fmt.Errorf(format, args...))
+}
+
+// sameJson compares values as json, so numbers of different types are equal
+func sameJson(one, two any) bool {
+	out1, err1 := json.Marshal(one)
+	out2, err2 := json.Marshal(two)
+	return err1 == nil && err2 == nil && string(out1) == string(out2)
+}
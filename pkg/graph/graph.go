@@ -6,6 +6,7 @@ import (
 	"os"
 
 	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	rspec "github.com/converged-computing/rainbow/pkg/jobspec"
 
 	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
 
@@ -53,9 +54,11 @@ type SlotCount struct {
 }
 
 // ExtractResourceSlots flattens a jobspec into a lookup of slots
+// A resource with a count range is counted at its minimum size.
 func ExtractResourceSlots(jobspec *v1.Jobspec) []SlotCount {
 
 	totals := []SlotCount{}
+	jobspec = rspec.AtMinimum(jobspec)
 
 	// Go sets loops to an initial value at start,
 	// so we need a function to recurse into nested resources
@@ -69,12 +72,7 @@ func ExtractResourceSlots(jobspec *v1.Jobspec) []SlotCount {
 			// This is the recursive bit
 			if resource.With != nil {
 				for _, with := range resource.With {
-					newSlot := SlotCount{
-						Count:   resource.Replicas,
-						Name:    with.Type,
-						Members: with.Count,
-						Parent:  resource.Type}
-					totals = append(totals, newSlot)
+					totals = append(totals, slotCounts(resource, with, 1)...)
 				}
 			}
 		} else {
@@ -92,6 +90,29 @@ func ExtractResourceSlots(jobspec *v1.Jobspec) []SlotCount {
 	return totals
 }
 
+// slotCounts returns the count for a resource in a slot. A slot nested in
+// the slot is not a resource type, so the counts are for the resources it
+// has, with as many members as there are replicas of it for each parent.
+func slotCounts(slot *v1.Resource, with v1.Resource, copies int32) []SlotCount {
+	if with.Type != "slot" {
+		return []SlotCount{{
+			Count:   slot.Replicas,
+			Name:    with.Type,
+			Members: with.Count * copies,
+			Parent:  slot.Type,
+		}}
+	}
+	replicas := with.Replicas
+	if replicas <= 0 {
+		replicas = 1
+	}
+	totals := []SlotCount{}
+	for _, nested := range with.With {
+		totals = append(totals, slotCounts(slot, nested, copies*replicas)...)
+	}
+	return totals
+}
+
 // A Slot for the slotlist
 type Slot struct {
 	Name  string
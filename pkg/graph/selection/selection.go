@@ -24,6 +24,22 @@ type SelectionAlgorithm interface {
 	Select([]string, map[string]types.ClusterState, string, bool) ([]string, error)
 }
 
+// A ParameterAlgorithm can say if it reads a state parameter, so rainbow
+// only computes parameters that are expensive (e.g., fit_count) when needed
+type ParameterAlgorithm interface {
+	UsesParameter(name string) bool
+}
+
+// UsesParameter determines if an algorithm reads a state parameter. We
+// assume it does if the algorithm cannot tell us.
+func UsesParameter(algorithm SelectionAlgorithm, name string) bool {
+	reader, ok := algorithm.(ParameterAlgorithm)
+	if !ok {
+		return true
+	}
+	return reader.UsesParameter(name)
+}
+
 // List returns known backends
 func List() map[string]SelectionAlgorithm {
 	return SelectionAlgorithms
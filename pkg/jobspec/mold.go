@@ -0,0 +1,317 @@
+package jobspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+)
+
+// Moldable jobs
+
+// The attribute of a resource with a range for its count. For a resource
+// with replicas (a slot) the range is for the replicas.
+var countAttribute = "count"
+
+// The largest size a range can have. This is more than any cluster we
+// know of could give, and keeps each step of a range within an int32.
+var maxCount int32 = 1 << 20
+
+// A CountRange is the sizes a resource can have, from min to max by step.
+// If there is a preferred size, we do not ask for more than it.
+type CountRange struct {
+	Min       int32 `json:"min"`
+	Max       int32 `json:"max,omitempty"`
+	Step      int32 `json:"step,omitempty"`
+	Preferred int32 `json:"preferred,omitempty"`
+}
+
+// Validate checks that a range has at least one size
+func (r *CountRange) Validate() error {
+	if r.Min < 1 {
+		return fmt.Errorf("min %d must be at least 1", r.Min)
+	}
+	if r.Max == 0 && r.Preferred == 0 {
+		return fmt.Errorf("a range needs a max or a preferred size")
+	}
+	if r.Max != 0 && r.Max < r.Min {
+		return fmt.Errorf("max %d is less than min %d", r.Max, r.Min)
+	}
+	if r.Max > maxCount || r.Preferred > maxCount {
+		return fmt.Errorf("a range cannot ask for more than %d", maxCount)
+	}
+	if r.Step < 0 {
+		return fmt.Errorf("step %d cannot be negative", r.Step)
+	}
+	if r.Preferred == 0 {
+		return nil
+	}
+	if r.Preferred < r.Min || (r.Max != 0 && r.Preferred > r.Max) {
+		return fmt.Errorf("preferred %d is not between min and max", r.Preferred)
+	}
+	if (r.Preferred-r.Min)%r.step() != 0 {
+		return fmt.Errorf("preferred %d is not a step from min %d", r.Preferred, r.Min)
+	}
+	return nil
+}
+
+// step defaults to 1
+func (r *CountRange) step() int32 {
+	if r.Step == 0 {
+		return 1
+	}
+	return r.Step
+}
+
+// count returns the number of sizes in a range. If there is a preferred
+// size, it is the largest.
+func (r *CountRange) count() int {
+	largest := r.Max
+	if r.Preferred != 0 {
+		largest = r.Preferred
+	}
+	if largest < r.Min {
+		return 1
+	}
+	return int((int64(largest)-int64(r.Min))/int64(r.step())) + 1
+}
+
+// size returns the size of a range at an index, from smallest to largest.
+// We compute it in 64 bits, and an index outside of the range is the
+// size at the end closest to it.
+func (r *CountRange) size(index int) int32 {
+	if index < 0 {
+		index = 0
+	}
+	if last := r.count() - 1; index > last {
+		index = last
+	}
+	size := int64(r.Min) + int64(index)*int64(r.step())
+	if size > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(size)
+}
+
+// A moldable is a resource with a range, named by its path in the jobspec
+type moldable struct {
+	path       string
+	resource   *v1.Resource
+	countRange *CountRange
+}
+
+// getRange returns the count range of a resource, if it has one
+func getRange(resource *v1.Resource) (*CountRange, bool, error) {
+	value, ok := resource.Attributes[countAttribute]
+	if !ok {
+		return nil, false, nil
+	}
+
+	// Attributes are generic, so we convert them by way of json
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, true, err
+	}
+	countRange := CountRange{}
+	err = json.Unmarshal(out, &countRange)
+	if err != nil {
+		return nil, true, fmt.Errorf("attribute %s is not a count range: %s", countAttribute, err)
+	}
+	return &countRange, true, countRange.Validate()
+}
+
+// findMoldable returns the resources of a jobspec with ranges, in the
+// order we mold them: groups by name, and a resource before those it has.
+func findMoldable(jobspec *v1.Jobspec) ([]moldable, error) {
+	names := make([]string, 0, len(jobspec.Resources))
+	for name := range jobspec.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	found := []moldable{}
+	for _, name := range names {
+		resource := jobspec.Resources[name]
+		err := walkMoldable(&resource, name, &found)
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// walkMoldable adds a resource (and those it has) with ranges. A resource
+// is named by the group, and the label (or type) of each resource below
+// it (e.g., ranks/core).
+func walkMoldable(resource *v1.Resource, path string, found *[]moldable) error {
+	countRange, ok, err := getRange(resource)
+	if err != nil {
+		return fmt.Errorf("resource %s: %s", path, err)
+	}
+	if ok {
+		*found = append(*found, moldable{path: path, resource: resource, countRange: countRange})
+	}
+	seen := map[string]bool{}
+	for i := range resource.With {
+		with := &resource.With[i]
+		name := with.Label
+		if name == "" {
+			name = with.Type
+		}
+
+		// Resources of the same type under one parent are numbered
+		if seen[name] {
+			name = fmt.Sprintf("%s%d", name, i)
+		}
+		seen[name] = true
+		err := walkMoldable(with, path+"/"+name, found)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasRanges determines if any resource of a jobspec has a count range
+func HasRanges(jobspec *v1.Jobspec) bool {
+	for _, resource := range jobspec.Resources {
+		if hasRange(&resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRange(resource *v1.Resource) bool {
+	_, ok := resource.Attributes[countAttribute]
+	if ok {
+		return true
+	}
+	for i := range resource.With {
+		if hasRange(&resource.With[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRanges checks every count range of a jobspec
+func ValidateRanges(jobspec *v1.Jobspec) error {
+	_, err := findMoldable(jobspec)
+	return err
+}
+
+// WithSizes returns a copy of a jobspec with a size for each range, by
+// path. The range is removed, and a range without a size is at its minimum.
+func WithSizes(jobspec *v1.Jobspec, sizes map[string]int32) (*v1.Jobspec, error) {
+	copied := v1.Jobspec{}
+	out, err := json.Marshal(jobspec)
+	if err != nil {
+		return &copied, err
+	}
+	err = json.Unmarshal(out, &copied)
+	if err != nil {
+		return &copied, err
+	}
+
+	// Top level resources are values, so we update them and put them back
+	for name, resource := range copied.Resources {
+		found := []moldable{}
+		err := walkMoldable(&resource, name, &found)
+		if err != nil {
+			return &copied, err
+		}
+		for _, m := range found {
+			size, ok := sizes[m.path]
+			if !ok {
+				size = m.countRange.size(0)
+			}
+			setSize(m.resource, size)
+		}
+		copied.Resources[name] = resource
+	}
+	return &copied, nil
+}
+
+// setSize sets the replicas of a slot (or the count of a resource), and
+// removes the range
+func setSize(resource *v1.Resource, size int32) {
+	if resource.Replicas > 0 || resource.Type == "slot" {
+		resource.Replicas = size
+	} else {
+		resource.Count = size
+	}
+	delete(resource.Attributes, countAttribute)
+}
+
+// AtMinimum returns a jobspec with every range at its smallest size. A
+// jobspec without ranges (or with one that is not valid) is returned as is.
+func AtMinimum(jobspec *v1.Jobspec) *v1.Jobspec {
+	if !HasRanges(jobspec) {
+		return jobspec
+	}
+	minimum, err := WithSizes(jobspec, map[string]int32{})
+	if err != nil {
+		return jobspec
+	}
+	return minimum
+}
+
+// Mold finds the largest size of each range of a jobspec that fits, and
+// returns them by path. The jobspec must fit with every range at its
+// minimum. Each range (in order) is the largest that fits with the ranges
+// before it at their chosen size and those after it at their minimum.
+// We assume that if a size does not fit, a larger size does not either.
+func Mold(jobspec *v1.Jobspec, fits func(*v1.Jobspec) (bool, error)) (map[string]int32, error) {
+	sizes := map[string]int32{}
+	found, err := findMoldable(jobspec)
+	if err != nil {
+		return sizes, err
+	}
+	for _, m := range found {
+		sizes[m.path] = m.countRange.size(0)
+	}
+	for _, m := range found {
+
+		// fitsAt determines if the jobspec fits with the range at an index
+		fitsAt := func(index int) (bool, error) {
+			sizes[m.path] = m.countRange.size(index)
+			candidate, err := WithSizes(jobspec, sizes)
+			if err != nil {
+				return false, err
+			}
+			return fits(candidate)
+		}
+
+		// Walk down from the largest size, which is often what fits. If it
+		// does not, binary search for the largest size below it that does.
+		low, high := 0, m.countRange.count()-1
+		if high > 0 {
+			isMatch, err := fitsAt(high)
+			if err != nil {
+				return sizes, err
+			}
+			if isMatch {
+				low = high
+			} else {
+				high = high - 1
+			}
+		}
+		for low < high {
+			middle := low + (high-low+1)/2
+			isMatch, err := fitsAt(middle)
+			if err != nil {
+				return sizes, err
+			}
+			if isMatch {
+				low = middle
+			} else {
+				high = middle - 1
+			}
+		}
+		sizes[m.path] = m.countRange.size(low)
+	}
+	return sizes, nil
+}
@@ -9,8 +9,11 @@ import (
 	"github.com/converged-computing/rainbow/pkg/database"
 	"github.com/converged-computing/rainbow/pkg/graph"
 	"github.com/converged-computing/rainbow/pkg/graph/selection"
+	"github.com/converged-computing/rainbow/pkg/types"
 
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 // Register a new cluster with the server
@@ -128,6 +131,9 @@ func (s *Server) RegisterSubsystem(_ context.Context, in *pb.RegisterRequest) (*
 	if in.Name == "" || in.Secret == "" || in.Nodes == "" || in.Subsystem == "" {
 		return nil, errors.New("subsystem nodes, name, cluster name and secret are required")
 	}
+	if in.Subsystem == types.SelfSubsystem {
+		return nil, fmt.Errorf("subsystem name %s is reserved", in.Subsystem)
+	}
 
 	// Validate the secret, this is for a specific cluster
 	_, err := s.db.ValidateClusterSecret(in.Name, in.Secret)
@@ -150,6 +156,63 @@ func (s *Server) RegisterSubsystem(_ context.Context, in *pb.RegisterRequest) (*
 	return &response, err
 }
 
+// copyStates copies the states from the graph, so the parameters we add
+// for selection do not change the graph's stored state
+func copyStates(states map[string]types.ClusterState) map[string]types.ClusterState {
+	copied := map[string]types.ClusterState{}
+	for cluster, state := range states {
+		copied[cluster] = types.ClusterState{}
+		for key, value := range state {
+			copied[cluster][key] = value
+		}
+	}
+	return copied
+}
+
+// addCapacity asks the graph how many copies of the jobspec fit on each
+// cluster, and adds the count to the states as fit_count. Clusters without
+// state are skipped, as selection does not consider them. The states should
+// already be copies.
+func (s *Server) addCapacity(
+	clusters []string,
+	states map[string]types.ClusterState,
+	jobspec string,
+) error {
+
+	jspec := js.Jobspec{}
+	err := yaml.Unmarshal([]byte(jobspec), &jspec)
+	if err != nil {
+		return err
+	}
+	capacity, err := s.graph.Capacity(&jspec, s.matchAlgorithm, clusters)
+	if err != nil {
+		return err
+	}
+	for cluster, state := range states {
+		count, ok := capacity[cluster]
+		if ok {
+			state[types.FitCountParameter] = count
+		}
+	}
+	return nil
+}
+
+// addScores adds the fraction of preferences each cluster satisfies (from
+// satisfy, in the request) to the states as match_score. The states should
+// already be copies.
+func addScores(
+	states map[string]types.ClusterState,
+	clusters []*pb.SubmitJobRequest_Cluster,
+) map[string]types.ClusterState {
+	for _, cluster := range clusters {
+		state, ok := states[cluster.Name]
+		if ok && cluster.MatchScore != nil {
+			state[types.MatchScoreParameter] = cluster.GetMatchScore()
+		}
+	}
+	return states
+}
+
 // SubmitJob submits a job to a specific cluster, or adds an entry to the database
 func (s *Server) SubmitJob(_ context.Context, in *pb.SubmitJobRequest) (*pb.SubmitJobResponse, error) {
 	if in == nil {
@@ -190,13 +253,6 @@ func (s *Server) SubmitJob(_ context.Context, in *pb.SubmitJobRequest) (*pb.Subm
 	}
 	log.Printf("📝️ received job %s for %d contender clusters", in.Name, len(clusters))
 
-	// Get state for clusters. Note that we allow clusters that are missing
-	// state data - given that the algorithm needs it, they are not included
-	states, err := s.graph.GetStates(clusters)
-	if err != nil {
-		return nil, err
-	}
-
 	// A request can customize this on the fly, but currently no support
 	// for options. We will need to add support for multiple algorithms
 	// and options in the rainbow config
@@ -216,6 +272,25 @@ func (s *Server) SubmitJob(_ context.Context, in *pb.SubmitJobRequest) (*pb.Subm
 		}
 		algo = selectAlgo
 	}
+
+	// Get state for clusters. Note that we allow clusters that are missing
+	// state data - given that the algorithm needs it, they are not included
+	states, err := s.graph.GetStates(clusters)
+	if err != nil {
+		return nil, err
+	}
+	states = copyStates(states)
+
+	// Add how many copies of the job fit on each cluster to the states, only
+	// if the algorithm reads it. Without it, the algorithm can still select.
+	if selection.UsesParameter(algo, types.FitCountParameter) {
+		err = s.addCapacity(clusters, states, in.Jobspec)
+		if err != nil {
+			log.Printf("warning: cannot count how many copies of job %s fit: %s\n", in.Name, err)
+		}
+	}
+	// And the fraction of preferences each satisfies, if there are any
+	states = addScores(states, in.Clusters)
 	// Use the algorithm to select a final cluster, providing states and the jobspec
 	selected, err := algo.Select(clusters, states, in.Jobspec, in.SatisfyOnly)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"github.com/converged-computing/rainbow/pkg/certs"
 	"github.com/converged-computing/rainbow/pkg/config"
 	"github.com/converged-computing/rainbow/pkg/database"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
 	"github.com/converged-computing/rainbow/pkg/graph/backend"
 	"github.com/converged-computing/rainbow/pkg/graph/selection"
 
@@ -46,6 +47,7 @@ type Server struct {
 	// graph database handle
 	graph              backend.GraphBackend
 	selectionAlgorithm selection.SelectionAlgorithm
+	matchAlgorithm     algorithm.MatchAlgorithm
 }
 
 // NewServer creates a new "scheduler" server
@@ -81,6 +83,17 @@ func NewServer(
 	}
 	log.Printf("🧩️ selection algorithm: %v", selectAlgo.Name())
 
+	// The match algorithm is used to ask the graph about capacity
+	matchName := cfg.Scheduler.Algorithms.Match.Name
+	if matchName == "" {
+		matchName = config.DefaultMatchAlgorithm
+	}
+	matchAlgo, err := algorithm.New(matchName, cfg.Scheduler.Algorithms.Match.Options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("🧩️ match algorithm: %v", matchAlgo.Name())
+
 	// Load the graph backend!
 	graphDB, err := backend.Get(cfg.GraphDatabase.Name)
 	if err != nil {
@@ -108,11 +121,18 @@ func NewServer(
 		secret:             cfg.Scheduler.Secret,
 		globalToken:        globalToken,
 		selectionAlgorithm: selectAlgo,
+		matchAlgorithm:     matchAlgo,
 		host:               host,
 		certManager:        cert,
 	}, nil
 }
 
+// validateClusterToken checks a cluster token for the graph service
+func (s *Server) validateClusterToken(cluster, token string) bool {
+	_, err := s.db.ValidateClusterToken(cluster, token)
+	return err == nil
+}
+
 func (s *Server) String() string {
 	return fmt.Sprintf("%s v%s", s.name, s.version)
 }
@@ -172,8 +192,11 @@ func (s *Server) serve(_ context.Context, lis net.Listener) error {
 	// This is the main rainbow scheduler service
 	pb.RegisterRainbowSchedulerServer(s.server, s)
 
-	// Add the graph backend to it
-	s.graph.RegisterService(s.server)
+	// Add the graph backend to it, which can validate cluster tokens
+	err := s.graph.RegisterService(s.server, s.validateClusterToken)
+	if err != nil {
+		return errors.Wrap(err, "failed to register graph service")
+	}
 
 	log.Printf("server listening: %v", s.listener.Addr())
 	if err := s.server.Serve(s.listener); err != nil && err.Error() != "closed" {
@@ -18,6 +18,15 @@ type Resource struct {
 // The algorithms are required to know what they are looking for
 type ClusterState map[string]interface{}
 
+// FitCountParameter is added to cluster states given to selection,
+// and is the number of copies of the jobspec the cluster can host
+const FitCountParameter = "fit_count"
+
+// MatchScoreParameter is added to cluster states given to selection when
+// the jobspec has preferences, and is the fraction of them (by weight) the
+// cluster satisfies
+const MatchScoreParameter = "match_score"
+
 // A vertex is defined by an identifier. We use an int
 // instead of a string because it's faster. Edges are other
 // vertices (and their identifiers) it's connected to.
@@ -28,13 +37,29 @@ type Vertex struct {
 	Unit       string        `json:"unit"`
 	Type       string        `json:"type"`
 
+	// The node id from the original JGF, used to report placements
+	NodeId string `json:"nodeId"`
+
 	// Link to another subsystem vertex
 	Subsystems map[string]map[int]*Edge `json:"subsystems"`
 
+	// Total size of each resource type below the vertex (in the same subsystem)
+	// This lets a search skip subtrees that cannot have what it needs
+	Descendants map[string]int64 `json:"descendants,omitempty"`
+
 	// Less commonly accessed (and standardized) metadaa
 	Metadata metadata.Metadata
 }
 
+// Contains determines if the vertex is the resource type, or if there is
+// at least a count (and at least one) of the resource type below it
+func (v *Vertex) Contains(resourceType string, count int64) bool {
+	if count < 1 {
+		count = 1
+	}
+	return v.Type == resourceType || v.Descendants[resourceType] >= count
+}
+
 // An edge in the graph has a source vertex (where it's defined from)
 // and a destination (the Vertex field below)
 type Edge struct {
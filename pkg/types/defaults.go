@@ -4,4 +4,8 @@ var (
 	// cluster == containment == nodes
 	DefaultDominantSubsystem = "cluster"
 	ContainsRelation         = "contains"
+
+	// Requirements for this subsystem are on the resource vertex itself
+	// (its own metadata) and not an edge to another subsystem
+	SelfSubsystem = "self"
 )
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Requirement is parsed from a jobspec requires entry, e.g., a field
+// of a subsystem vertex with a version in a range. It is parsed once when
+// needs are made, and then checked against the subsystem edges we visit
+// (or the resource vertex itself, for the self subsystem).
+type Requirement interface {
+
+	// The operator (e.g., range) and the metadata field it checks
+	Operator() string
+	Field() string
+
+	// Operands for the operator (e.g., the min and max of a range)
+	Operands() map[string]string
+
+	// Satisfies determines if a vertex (of a subsystem edge) meets the requirement
+	Satisfies(vtx *Vertex) bool
+}
+
+// A SubsystemNeed is a requirement, and if it is satisfied for the
+// slot (or resource) we are currently searching
+type SubsystemNeed struct {
+	Requirement
+	Satisfied bool
+}
+
+// String describes the requirement, e.g., range(field=version, min=0.5.1)
+func (n *SubsystemNeed) String() string {
+	return DescribeRequirement(n.Requirement)
+}
+
+// DescribeRequirement describes a requirement with its operands in order
+func DescribeRequirement(req Requirement) string {
+	operands := req.Operands()
+	keys := make([]string, 0, len(operands))
+	for key := range operands {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	described := []string{fmt.Sprintf("field=%s", req.Field())}
+	for _, key := range keys {
+		described = append(described, fmt.Sprintf("%s=%s", key, operands[key]))
+	}
+	return fmt.Sprintf("%s(%s)", req.Operator(), strings.Join(described, ", "))
+}
+
+// Copy returns needs with the same requirements, and the same state
+func (m MatchAlgorithmNeeds) Copy() MatchAlgorithmNeeds {
+	copied := MatchAlgorithmNeeds{}
+	for subsystem, needs := range m {
+		copied[subsystem] = make([]*SubsystemNeed, 0, len(needs))
+		for _, need := range needs {
+			copied[subsystem] = append(copied[subsystem], &SubsystemNeed{
+				Requirement: need.Requirement,
+				Satisfied:   need.Satisfied,
+			})
+		}
+	}
+	return copied
+}
+
+// Satisfied determines if every need (for every subsystem) is satisfied
+func (m MatchAlgorithmNeeds) Satisfied() bool {
+	for _, needs := range m {
+		for _, need := range needs {
+			if !need.Satisfied {
+				return false
+			}
+		}
+	}
+	return true
+}
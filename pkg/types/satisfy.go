@@ -0,0 +1,135 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+)
+
+var (
+	// A resource type requested by the jobspec is not known to the cluster
+	ReasonResourceMissing = "missing"
+
+	// The cluster does not have enough of a resource type in total
+	ReasonResourceCount = "count"
+
+	// The depth first search could not find enough slots
+	ReasonSlotUnsatisfied = "slot"
+)
+
+// A SatisfyResult is returned by a graph backend to describe matches
+type SatisfyResult struct {
+
+	// Clusters that can satisfy the request
+	Clusters []string
+
+	// Explanations for clusters that cannot, only populated if requested
+	Mismatches map[string][]Explanation
+
+	// Vertices chosen for each slot replica, by cluster (if supported)
+	Placements map[string][]SlotPlacement
+
+	// Fraction of the weight of preferences satisfied, by cluster that
+	// matches. Only populated when the jobspec has preferences.
+	Scores map[string]float64
+
+	// Sizes chosen for the count ranges of a jobspec (by resource path),
+	// by cluster that matches. Only populated when the jobspec has ranges.
+	Sizes map[string]map[string]int32
+
+	// Counts of clusters searched, matched, and not matched (if supported)
+	TotalClusters   int32
+	TotalMatches    int32
+	TotalMismatches int32
+}
+
+// An Explanation describes one reason a cluster could not satisfy a request
+type Explanation struct {
+
+	// One of missing, count, or slot
+	Reason string
+
+	// The resource type (or slot label) that was not satisfied
+	Resource string
+
+	// The number needed vs. the number found. For a slot, these are slot counts
+	Needed int32
+	Found  int32
+
+	// Needs remaining when the search stopped (e.g., core=2, or node:io:<attribute>)
+	Unmet []string
+}
+
+// NewSatisfyResult creates an empty result
+func NewSatisfyResult() *SatisfyResult {
+	return &SatisfyResult{
+		Clusters:   []string{},
+		Mismatches: map[string][]Explanation{},
+		Placements: map[string][]SlotPlacement{},
+		Scores:     map[string]float64{},
+		Sizes:      map[string]map[string]int32{},
+	}
+}
+
+// String summarizes an explanation on one line
+func (e *Explanation) String() string {
+	switch e.Reason {
+	case ReasonResourceMissing:
+		return fmt.Sprintf("resource type %s is not known to the cluster", e.Resource)
+	case ReasonResourceCount:
+		return fmt.Sprintf("resource type %s has %d, %d needed", e.Resource, e.Found, e.Needed)
+	}
+	return fmt.Sprintf("slot %s found %d of %d needed", e.Resource, e.Found, e.Needed)
+}
+
+// ClusterNames returns sorted names of clusters with explanations
+func (r *SatisfyResult) ClusterNames() []string {
+	names := []string{}
+	for name := range r.Mismatches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A SlotPlacement holds the vertices chosen for one replica of a slot.
+// This is a hint for the receiving cluster, not a reservation.
+type SlotPlacement struct {
+
+	// The slot label from the jobspec, and the replica index
+	Slot    string `json:"slot"`
+	Replica int32  `json:"replica"`
+
+	// JGF node ids in the dominant subsystem
+	Vertices []string `json:"vertices"`
+
+	// JGF node ids in other subsystems, by subsystem name
+	Subsystems map[string][]string `json:"subsystems,omitempty"`
+}
+
+// NewSlotPlacement starts an empty placement for a slot replica
+func NewSlotPlacement(slot string, replica int32) *SlotPlacement {
+	return &SlotPlacement{
+		Slot:       slot,
+		Replica:    replica,
+		Vertices:   []string{},
+		Subsystems: map[string][]string{},
+	}
+}
+
+// AddSubsystemVertex adds a vertex from a subsystem that is not dominant
+func (p *SlotPlacement) AddSubsystemVertex(subsystem, nodeId string) {
+	ids, ok := p.Subsystems[subsystem]
+	if !ok {
+		ids = []string{}
+	}
+	p.Subsystems[subsystem] = append(ids, nodeId)
+}
+
+// A Preference is a copy of a jobspec where one preference is required,
+// and how much it counts toward the score of a cluster that satisfies it
+type Preference struct {
+	Weight  float64
+	Jobspec *v1.Jobspec
+}
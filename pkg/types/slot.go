@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"sort"
 )
 
 // Resource needs can be used for:
@@ -11,8 +12,8 @@ import (
 //  2. A single resource type, in which case we define Type and
 //     ignore Found/Needed
 //
-// subsystem -> attribute -> isSatisfied
-type MatchAlgorithmNeeds map[string]map[string]bool
+// subsystem -> needs (requirements and if they are satisfied)
+type MatchAlgorithmNeeds map[string][]*SubsystemNeed
 
 // Serialize slot resource needs into a struct that is easier to parse
 type ResourceNeeds struct {
@@ -20,7 +21,7 @@ type ResourceNeeds struct {
 	ResourceSatisfied  bool
 
 	// Lookup by vertex type
-	// type -> subsystem -> attribute -> isSatisfied
+	// type -> subsystem -> needs
 	Subsystems map[string]MatchAlgorithmNeeds
 	Resources  map[string]int32
 
@@ -36,9 +37,17 @@ type ResourceNeeds struct {
 	ResourcesOriginal  map[string]int32
 }
 
+// Reset restores needs from the originals to search for the next slot.
+// We copy so that counting down the next slot does not change the originals.
 func (s *ResourceNeeds) Reset() {
-	s.Subsystems = s.SubsystemsOriginal
-	s.Resources = s.ResourcesOriginal
+	s.Subsystems = map[string]MatchAlgorithmNeeds{}
+	for resourceType, typeNeeds := range s.SubsystemsOriginal {
+		s.Subsystems[resourceType] = typeNeeds.Copy()
+	}
+	s.Resources = map[string]int32{}
+	for resourceType, count := range s.ResourcesOriginal {
+		s.Resources[resourceType] = count
+	}
 	s.SubsystemSatisfied = false
 	s.ResourceSatisfied = false
 }
@@ -53,10 +62,10 @@ func (s *ResourceNeeds) SummarizeRemaining() string {
 			// Also get number of subsystem needs
 			sNeeds, ok := s.Subsystems[resourceType]
 			if ok {
-				for subsystem, attributes := range sNeeds {
+				for subsystem, needs := range sNeeds {
 					count = 0
-					for _, isSatsified := range attributes {
-						if !isSatsified {
+					for _, need := range needs {
+						if !need.Satisfied {
 							count += 1
 						}
 					}
@@ -70,10 +79,10 @@ func (s *ResourceNeeds) SummarizeRemaining() string {
 
 	// Now add subsystem needs
 	for resourceType, typeNeeds := range s.Subsystems {
-		for subsystem, sNeeds := range typeNeeds {
-			for attribute, isSatisfied := range sNeeds {
-				if !isSatisfied {
-					byType[resourceType] += fmt.Sprintf(" %s:%s", subsystem, attribute)
+		for subsystem, needs := range typeNeeds {
+			for _, need := range needs {
+				if !need.Satisfied {
+					byType[resourceType] += fmt.Sprintf(" %s:%s", subsystem, need)
 				}
 			}
 		}
@@ -88,6 +97,28 @@ func (s *ResourceNeeds) SummarizeRemaining() string {
 	return summary
 }
 
+// Remaining returns a sorted list of needs that are not yet satisfied,
+// with counts as <type>=<count> and subsystems as <type>:<subsystem>:<requirement>
+func (s *ResourceNeeds) Remaining() []string {
+	remaining := []string{}
+	for resourceType, count := range s.Resources {
+		if count > 0 {
+			remaining = append(remaining, fmt.Sprintf("%s=%d", resourceType, count))
+		}
+	}
+	for resourceType, typeNeeds := range s.Subsystems {
+		for subsystem, needs := range typeNeeds {
+			for _, need := range needs {
+				if !need.Satisfied {
+					remaining = append(remaining, fmt.Sprintf("%s:%s:%s", resourceType, subsystem, need))
+				}
+			}
+		}
+	}
+	sort.Strings(remaining)
+	return remaining
+}
+
 func (s *ResourceNeeds) Satisfied() bool {
 	return s.Found >= s.Needed
 }
@@ -109,13 +140,9 @@ func (s *ResourceNeeds) AreSubsystemsSatisfied() bool {
 	if s.SubsystemSatisfied {
 		return true
 	}
-	for _, needs := range s.Subsystems {
-		for _, typeNeeds := range needs {
-			for _, isSatisfied := range typeNeeds {
-				if !isSatisfied {
-					return false
-				}
-			}
+	for _, typeNeeds := range s.Subsystems {
+		if !typeNeeds.Satisfied() {
+			return false
 		}
 	}
 	// Cache result so we don't redo big loops
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sort"
 )
 
 // PathExists determines if a path exists
@@ -37,6 +38,16 @@ func Copy(list []string) []string {
 	return copied
 }
 
+// Keys returns the sorted keys of a lookup
+func Keys(lookup map[string]string) []string {
+	keys := []string{}
+	for key := range lookup {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Diff returns the difference between two sets of strings
 func Diff(one, two []string) []string {
 	var difference []string
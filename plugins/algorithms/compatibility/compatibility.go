@@ -0,0 +1,167 @@
+package compatibility
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+// CompatibilityType matches resource vertices to a compatibility artifact
+// the jobspec references. It is made for a jobspec with the requirements
+// of the artifact, and requires entries are checked like the match algorithm.
+type CompatibilityType struct {
+
+	// The type of resource vertex the artifact describes (e.g., node)
+	resourceType string
+
+	// Requirements on the vertex for each attribute of the artifact
+	requirements []types.Requirement
+}
+
+var (
+	description   = "match nodes to a compatibility artifact (e.g., kernel, MPI, ABI) referenced by the jobspec"
+	matcherName   = "compatibility"
+	defaultType   = "node"
+	requiresMatch = match.MatchType{}
+	errNoArtifact = errors.New("the jobspec does not reference a compatibility artifact")
+	errNotInline  = errors.New("the compatibility artifact must be inline (spec), a path is only read by the client")
+)
+
+func (c CompatibilityType) Name() string {
+	return matcherName
+}
+
+func (c CompatibilityType) Description() string {
+	return description
+}
+
+// Init checks options. The algorithm does not have any.
+func (c CompatibilityType) Init(options map[string]string) error {
+	for key := range options {
+		return fmt.Errorf("%q is not a known option for the %s algorithm", key, matcherName)
+	}
+	return nil
+}
+
+// PrepareJobspec reads an artifact the jobspec references by path, and adds
+// it to the jobspec inline, since the graph cannot read our files
+func (c CompatibilityType) PrepareJobspec(jobspec *v1.Jobspec) error {
+	ref, ok, err := getReference(jobspec)
+	if err != nil || !ok || ref.Spec != nil {
+		return err
+	}
+	err = ref.load()
+	if err != nil {
+		return err
+	}
+
+	// The attribute is saved as generic values, like any other
+	out, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	attribute := map[string]any{}
+	err = json.Unmarshal(out, &attribute)
+	if err != nil {
+		return err
+	}
+	jobspec.Attributes[attributeName] = attribute
+	return nil
+}
+
+// ForJobspec returns a matcher with the requirements of the artifact the
+// jobspec references. Without one, only requires entries are checked. The
+// graph calls this, so the artifact must be inline, and we never read a
+// path (PrepareJobspec on the client does that).
+func (c CompatibilityType) ForJobspec(jobspec *v1.Jobspec) (algorithm.MatchAlgorithm, error) {
+	ref, ok, err := getReference(jobspec)
+	if err != nil {
+		return c, err
+	}
+	if !ok {
+		rlog.Debugf("  compatibility: %s\n", errNoArtifact)
+		return CompatibilityType{}, nil
+	}
+	err = ref.check()
+	if err != nil {
+		return c, err
+	}
+	if ref.Spec == nil {
+		return c, errNotInline
+	}
+	requirements, err := ref.requirements()
+	if err != nil {
+		return c, err
+	}
+	resourceType := ref.Type
+	if resourceType == "" {
+		resourceType = defaultType
+	}
+	return CompatibilityType{resourceType: resourceType, requirements: requirements}, nil
+}
+
+// ResourceNeeds returns needs on the resource vertex itself for each
+// attribute of the artifact, if the resource is the type it describes
+func (c CompatibilityType) ResourceNeeds(resource *v1.Resource) types.MatchAlgorithmNeeds {
+	needs := types.MatchAlgorithmNeeds{}
+	if resource.Type != c.resourceType || len(c.requirements) == 0 {
+		return needs
+	}
+	for _, req := range c.requirements {
+		needs[types.SelfSubsystem] = append(needs[types.SelfSubsystem], &types.SubsystemNeed{Requirement: req})
+	}
+	return needs
+}
+
+// ValidateJobspec checks the artifact the jobspec references, and its
+// requires entries, so a mistake is an error when the job is submit
+func (c CompatibilityType) ValidateJobspec(jobspec *v1.Jobspec) error {
+	errs := []error{}
+	_, ok, err := getReference(jobspec)
+	if err == nil && !ok {
+		err = errNoArtifact
+	}
+	if err == nil {
+		_, err = c.ForJobspec(jobspec)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("compatibility: %w", err))
+	}
+	err = requiresMatch.ValidateJobspec(jobspec)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// CheckSubsystemEdge checks the needs for a subsystem edge, the same as the
+// match algorithm. The artifact is checked against the vertex itself.
+func (c CompatibilityType) CheckSubsystemEdge(
+	slotNeeds types.MatchAlgorithmNeeds,
+	edge *types.Edge,
+) {
+	requiresMatch.CheckSubsystemEdge(slotNeeds, edge)
+}
+
+// GenerateCypher writes the query for the needs of a resource. Needs for
+// the artifact are on the resource node, like the self subsystem.
+func (c CompatibilityType) GenerateCypher(resource string, matchNeeds types.MatchAlgorithmNeeds) string {
+	return requiresMatch.GenerateCypher(resource, matchNeeds)
+}
+
+// Preferences are requires entries, and scored the same as the match algorithm
+func (c CompatibilityType) Preferences(jobspec *v1.Jobspec) ([]types.Preference, error) {
+	return requiresMatch.Preferences(jobspec)
+}
+
+// Add the match algorithm to be known to rainbow
+func init() {
+	algo := CompatibilityType{}
+	algorithm.Register(algo)
+}
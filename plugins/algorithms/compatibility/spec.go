@@ -0,0 +1,225 @@
+package compatibility
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/plugins/algorithms/match"
+	"sigs.k8s.io/yaml"
+)
+
+// The jobspec attribute that references a compatibility artifact
+const attributeName = "compatibility"
+
+// A Spec is a compatibility artifact (in the style of compspec), with sets
+// of attributes that describe what an application needs of a node
+type Spec struct {
+	Version         string          `json:"version,omitempty"`
+	Kind            string          `json:"kind,omitempty"`
+	Metadata        Metadata        `json:"metadata,omitempty"`
+	Compatibilities []Compatibility `json:"compatibilities"`
+}
+
+type Metadata struct {
+	Name string `json:"name,omitempty"`
+}
+
+// A Compatibility is a named set of attributes, e.g., for MPI
+type Compatibility struct {
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// A Reference is the compatibility attribute of a jobspec. It has a path
+// to an artifact, or the artifact inline (spec), the type of resource
+// vertex the artifact describes, and the rule to compare each attribute.
+type Reference struct {
+	Path  string            `json:"path,omitempty"`
+	Spec  *Spec             `json:"spec,omitempty"`
+	Type  string            `json:"type,omitempty"`
+	Rules map[string]string `json:"rules,omitempty"`
+}
+
+// Rules compare an attribute of the artifact to the field of a vertex
+var (
+	ruleExact   = "exact"
+	ruleSemver  = "semver"
+	ruleNumeric = "numeric"
+	rules       = []string{ruleExact, ruleSemver, ruleNumeric}
+)
+
+// Requires keys for each comparison of a rule. A bound without a
+// comparison (e.g., 5.14) means at least that bound.
+var comparisons = map[string]map[string]string{
+	ruleSemver:  {">=": "min", "<=": "max", "": "min"},
+	ruleNumeric: {">=": "gte", ">": "gt", "<=": "lte", "<": "lt", "": "gte"},
+}
+
+// getReference returns the compatibility reference of a jobspec, if it has one
+// The attribute can be a path to the artifact, or the reference itself.
+func getReference(jobspec *v1.Jobspec) (*Reference, bool, error) {
+	value, ok := jobspec.Attributes[attributeName]
+	if !ok {
+		return nil, false, nil
+	}
+	ref := Reference{}
+	path, ok := value.(string)
+	if ok {
+		ref.Path = path
+		return &ref, true, nil
+	}
+
+	// Attributes are generic, so we convert them by way of json
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, true, err
+	}
+	err = json.Unmarshal(out, &ref)
+	if err != nil {
+		return nil, true, fmt.Errorf("attribute %s is not a compatibility reference: %s", attributeName, err)
+	}
+	return &ref, true, nil
+}
+
+// check makes sure a reference has a path or a spec, and not both
+func (r *Reference) check() error {
+	if r.Spec != nil && r.Path != "" {
+		return fmt.Errorf("a compatibility reference has a path or a spec, not both")
+	}
+	if r.Spec == nil && r.Path == "" {
+		return fmt.Errorf("a compatibility reference needs a path or a spec")
+	}
+	return nil
+}
+
+// load reads the artifact from the path, if it is not inline. The path is
+// a file of the client, so only the client loads a reference.
+func (r *Reference) load() error {
+	err := r.check()
+	if err != nil || r.Spec != nil {
+		return err
+	}
+	out, err := os.ReadFile(r.Path)
+	if err != nil {
+		return err
+	}
+	spec := Spec{}
+	err = yaml.Unmarshal(out, &spec)
+	if err != nil {
+		return fmt.Errorf("compatibility spec %s is not valid: %s", r.Path, err)
+	}
+	r.Spec = &spec
+	r.Path = ""
+	return nil
+}
+
+// attributes returns the value of every attribute in the artifact, by name
+// An attribute in more than one compatibility must have the same value.
+func (r *Reference) attributes() (map[string]string, error) {
+	values := map[string]string{}
+	for _, compat := range r.Spec.Compatibilities {
+		for name, value := range compat.Attributes {
+			switch value.(type) {
+			case map[string]any, []any, nil:
+				return values, fmt.Errorf("attribute %s of %s must be a string, number, or boolean", name, compat.Name)
+			}
+			str := fmt.Sprint(value)
+			existing, ok := values[name]
+			if ok && existing != str {
+				return values, fmt.Errorf("attribute %s is %s and %s", name, existing, str)
+			}
+			values[name] = str
+		}
+	}
+	return values, nil
+}
+
+// requirements parses the attributes of the artifact into requirements on
+// the resource vertex itself, with the rule for each attribute
+func (r *Reference) requirements() ([]types.Requirement, error) {
+	requirements := []types.Requirement{}
+	values, err := r.attributes()
+	if err != nil {
+		return requirements, err
+	}
+	for name, rule := range r.Rules {
+		_, ok := values[name]
+		if !ok {
+			return requirements, fmt.Errorf("rule for %s is not an attribute of the compatibility spec", name)
+		}
+		if !contains(rules, rule) {
+			return requirements, fmt.Errorf("rule %s for %s is not one of %s", rule, name, strings.Join(rules, ", "))
+		}
+	}
+
+	// Attributes are sorted so the requirements (and queries) are the same each time
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request, err := requires(name, values[name], r.Rules[name])
+		if err != nil {
+			return requirements, err
+		}
+		parsed, err := match.ParseRequirements(request, match.Options{})
+		if err != nil {
+			return requirements, fmt.Errorf("attribute %s: %s", name, err)
+		}
+		requirements = append(requirements, parsed...)
+	}
+	return requirements, nil
+}
+
+// requires writes a requires entry for the self subsystem that compares an
+// attribute with a rule. Exact is the default, and semver and numeric
+// values can be bounds, e.g., ">=4,<8".
+func requires(name, value, rule string) (map[string]string, error) {
+	request := map[string]string{"name": types.SelfSubsystem, "field": name}
+	if rule == "" || rule == ruleExact {
+		request["match"] = value
+		return request, nil
+	}
+	for _, term := range strings.Split(value, ",") {
+		term = strings.TrimSpace(term)
+		operator := ""
+		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(term, prefix) {
+				operator = prefix
+				term = strings.TrimSpace(strings.TrimPrefix(term, prefix))
+				break
+			}
+		}
+		keys := []string{comparisons[rule][operator]}
+		if operator == "=" {
+			keys = []string{comparisons[rule][">="], comparisons[rule]["<="]}
+		}
+		for _, key := range keys {
+			if key == "" {
+				return request, fmt.Errorf("%s %s cannot be compared with %s", rule, name, operator)
+			}
+			_, exists := request[key]
+			if exists {
+				return request, fmt.Errorf("%s %s has more than one %s bound", rule, name, key)
+			}
+			request[key] = term
+		}
+	}
+	return request, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
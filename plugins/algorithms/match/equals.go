@@ -2,7 +2,6 @@ package match
 
 import (
 	"fmt"
-	"strings"
 
 	rlog "github.com/converged-computing/rainbow/pkg/logger"
 	"github.com/converged-computing/rainbow/pkg/types"
@@ -10,54 +9,87 @@ import (
 
 // Equality check (an exact match)
 
-type EqualsType struct{}
+// MatchEqualRequest is satisfied by a field with an exact value
 type MatchEqualRequest struct {
-	Field string
+	fieldRequest
 	Value string
 }
 
-// Compress the match request into a parseable field
-func (req *MatchEqualRequest) Compress() string {
-	value := fmt.Sprintf("match||field=%s", req.Field)
-	value = fmt.Sprintf("%s||value=%s", value, req.Value)
-	return value
+// parseEqual parses a requires entry with match
+func parseEqual(field fieldRequest, request map[string]string) (types.Requirement, error) {
+	value := request["match"]
+	if value == "" {
+		return nil, fmt.Errorf("match requires a value")
+	}
+	return &MatchEqualRequest{fieldRequest: field, Value: value}, nil
 }
 
-func NewMatchEqualRequest(value string) *MatchEqualRequest {
-	req := MatchEqualRequest{}
-	pieces := strings.Split(value, "||")
-	for _, piece := range pieces {
-		if strings.HasPrefix(piece, "field=") {
-			req.Field = strings.ReplaceAll(piece, "field=", "")
-		} else if strings.HasPrefix(piece, "value=") {
-			req.Value = strings.ReplaceAll(piece, "value=", "")
-		}
-	}
-	return &req
+func (req *MatchEqualRequest) Operator() string {
+	return "match"
 }
 
-// MatchEqualityEdge looks for an exact match
-func MatchEqualityEdge(matchExpression string, edge *types.Edge) bool {
-	req := NewMatchEqualRequest(matchExpression)
+func (req *MatchEqualRequest) Operands() map[string]string {
+	return map[string]string{"value": req.Value}
+}
+
+// Satisfies looks for an exact match
+func (req *MatchEqualRequest) Satisfies(vtx *types.Vertex) bool {
 
 	// Get the field requested by the jobspec
-	toMatch, err := edge.Vertex.Metadata.GetStringElement(req.Field)
-	if err != nil {
+	toMatch, ok := elementString(&vtx.Metadata, req.field)
+	if !ok {
 		return false
 	}
+	rlog.Debugf("      => Found field requested for exact match %s\n", toMatch)
 
-	rlog.Debugf("      => Found field requested for range match %s\n", toMatch)
 	// These are the conditions of being satisifed, the value we got from the vertex
 	// matches the value provided in the slot request
-	return toMatch == req.Value
+	return req.options.equal(toMatch, req.Value)
+}
+
+// Cypher writes the cypher predicate for a match of a subsystem node
+func (req *MatchEqualRequest) Cypher(node string) string {
+	return fmt.Sprintf("%s = %s", req.options.cypherField(node, req.field), cypherString(req.options.fold(req.Value)))
+}
+
+// Negation (the field is defined, and is not the value)
+
+// MatchNotRequest is satisfied by a field that is not a value
+type MatchNotRequest struct {
+	fieldRequest
+	Value string
 }
 
-// MatchEqualityCypher writes the lines of cypher for a match
-func MatchEqualityCypher(subsystem, matchExpression string) string {
-	req := NewMatchEqualRequest(matchExpression)
+// parseNot parses a requires entry with not
+func parseNot(field fieldRequest, request map[string]string) (types.Requirement, error) {
+	value := request["not"]
+	if value == "" {
+		return nil, fmt.Errorf("not requires a value")
+	}
+	return &MatchNotRequest{fieldRequest: field, Value: value}, nil
+}
+
+func (req *MatchNotRequest) Operator() string {
+	return "not"
+}
+
+func (req *MatchNotRequest) Operands() map[string]string {
+	return map[string]string{"value": req.Value}
+}
+
+// Satisfies looks for a value that is not the one requested
+// Like the other checks, this is for one edge: a vertex with edges to
+// lustre and another filesystem still has a filesystem that is not lustre.
+func (req *MatchNotRequest) Satisfies(vtx *types.Vertex) bool {
+	toMatch, ok := elementString(&vtx.Metadata, req.field)
+	if !ok {
+		return false
+	}
+	rlog.Debugf("      => Found field requested for negation %s\n", toMatch)
+	return !req.options.equal(toMatch, req.Value)
+}
 
-	// req.Name => the subsystem
-	query := fmt.Sprintf("\n-[contains]-(%s:Node {subsystem: '%s'})", subsystem, subsystem)
-	query += fmt.Sprintf("\nWHERE %s.%s = '%s'", subsystem, req.Field, req.Value)
-	return query
+// Cypher writes the cypher predicate for a negation
+func (req *MatchNotRequest) Cypher(node string) string {
+	return fmt.Sprintf("%s <> %s", req.options.cypherField(node, req.field), cypherString(req.options.fold(req.Value)))
 }
@@ -1,17 +1,23 @@
 package match
 
 import (
-	"strings"
+	"fmt"
+	"sort"
+	"strconv"
 
+	"github.com/converged-computing/jsongraph-go/jsongraph/metadata"
 	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
 	rlog "github.com/converged-computing/rainbow/pkg/logger"
 	"github.com/converged-computing/rainbow/pkg/types"
 )
 
-type MatchType struct{}
+// MatchType checks requirements with options (the defaults if it has none)
+type MatchType struct {
+	options Options
+}
 
 var (
-	description = "match single values or ranges for subsystem job assignment"
+	description = "match values, ranges, numeric bounds, sets, or patterns for subsystem job assignment"
 	matcherName = "match"
 )
 
@@ -23,127 +29,295 @@ func (s MatchType) Description() string {
 	return description
 }
 
-// checkSubsystemNeeds is a shared function to loop over needs for an edge to check
-// the needs should already be scoepd to a subsystem
-func CheckSubsystemNeeds(needs map[string]bool, edge *types.Edge) map[string]bool {
+// A kind of requirement has operators (keys in a requires entry), and
+// parses an entry that uses them into a requirement
+type kind struct {
+	operators []string
+	parse     func(field fieldRequest, request map[string]string) (types.Requirement, error)
+}
 
-	// Yuck, this would be better as a query! Oh well.
-	for k := range needs {
-		rlog.Debugf("      => Looking at edge %s '%s' for %s that needs %s\n", edge.Subsystem, edge.Vertex.Type, edge.Subsystem, k)
+// Different kinds (e.g., match and range) can be set in the same entry
+var kinds = []kind{
+	{[]string{"match"}, parseEqual},
+	{[]string{"min", "max"}, parseRange},
+	{numericOperators, parseNumeric},
+	{[]string{"in", "not_in"}, parseSet},
+	{[]string{"regex"}, parseRegex},
+	{[]string{"not"}, parseNot},
+}
 
-		// We care if the attribute is marked as a range or exact match
-		// Passing slotNeeds to the function (pointer) updates in place
-		if strings.HasPrefix(k, "match") {
-			if MatchEqualityEdge(k, edge) {
-				rlog.Debugf("      => Edge '%s' satisfies subsystem %s %s\n", edge.Vertex.Type, edge.Subsystem, k)
-				needs[k] = true
-			}
+// Keys in a requires entry that are not operators
+var requiresKeys = []string{"name", "field"}
 
-		} else if strings.HasPrefix(k, "range") {
-			if MatchRangeEdge(k, edge) {
-				rlog.Debugf("      => Edge '%s' satisfies subsystem %s %s\n", edge.Vertex.Type, edge.Subsystem, k)
-				needs[k] = true
-			}
+// fieldRequest is the metadata field that every requirement checks, and
+// the options to check it with
+type fieldRequest struct {
+	field   string
+	options Options
+}
+
+func (r fieldRequest) Field() string {
+	return r.field
+}
+
+// ParseRequirements parses a requires entry into requirements. It needs a
+// subsystem name, a field, and at least one known operator, and the values
+// for each operator (and the path or preference, if there is one) must parse.
+// Each requirement is checked with the options.
+func ParseRequirements(request map[string]string, options Options) ([]types.Requirement, error) {
+	requirements := []types.Requirement{}
+	if request["name"] == "" {
+		return requirements, fmt.Errorf("name (the subsystem) is required")
+	}
+	known := map[string]bool{}
+	for _, keys := range [][]string{requiresKeys, pathKeys, preferKeys} {
+		for _, key := range keys {
+			known[key] = true
+		}
+	}
+	for _, k := range kinds {
+		for _, operator := range k.operators {
+			known[operator] = true
+		}
+	}
+	keys := []string{}
+	for key := range request {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			return requirements, fmt.Errorf("%q is not a known operator", key)
+		}
+	}
+	_, _, err := parsePreference(request)
+	if err != nil {
+		return requirements, err
+	}
+	field := request["field"]
+	for _, k := range kinds {
+		used := false
+		for _, operator := range k.operators {
+			_, ok := request[operator]
+			used = used || ok
+		}
+		if !used {
+			continue
 		}
+		if field == "" {
+			return requirements, fmt.Errorf("field is required for subsystem %s", request["name"])
+		}
+		req, err := k.parse(fieldRequest{field: field, options: options}, request)
+		if err != nil {
+			return requirements, err
+		}
+		if options.IgnoreMissing {
+			req = &missingRequest{req}
+		}
+		requirements = append(requirements, req)
+	}
+	if len(requirements) == 0 {
+		return requirements, fmt.Errorf("an operator is required for subsystem %s", request["name"])
+	}
+
+	// A path applies to every requirement in the entry
+	p, ok, err := parsePath(request, options)
+	if err != nil || !ok {
+		return requirements, err
+	}
+	for i, req := range requirements {
+		requirements[i] = &PathRequest{Requirement: req, path: *p}
+	}
+	return requirements, nil
+}
+
+// GetResourceNeeds parses a requires entry into needs for its subsystem.
+// This is exposed so other matchers can use the same requirements. An
+// entry that does not parse is a need that is never satisfied, since
+// ignoring it would match more than was asked for.
+func GetResourceNeeds(request map[string]string, options Options) []*types.SubsystemNeed {
+	needs := []*types.SubsystemNeed{}
+	requirements, err := ParseRequirements(request, options)
+	if err != nil {
+		rlog.Debugf("      => Requirement %v is not valid: %s\n", request, err)
+		requirements = []types.Requirement{&invalidRequest{fieldRequest{field: request["field"], options: options}, err}}
+	}
+	for _, req := range requirements {
+		needs = append(needs, &types.SubsystemNeed{Requirement: req})
 	}
 	return needs
 }
 
-// checkSubsystemEdge evaluates a node edge in the dominant subsystem for a
-// subsystem attribute. E.g., if the io subsystem provides
-// Vertex (from dominant subsysetem) is only passed in for informational purposes
+// CheckSubsystemNeeds is a shared function to check needs against an edge.
+// The needs should already be scoped to a subsystem, and those that are
+// satisfied by the edge are updated.
+func CheckSubsystemNeeds(needs []*types.SubsystemNeed, edge *types.Edge) {
+	for _, need := range needs {
+		if need.Satisfied {
+			continue
+		}
+		rlog.Debugf("      => Looking at edge %s '%s' that needs %s\n", edge.Subsystem, edge.Vertex.Type, need)
+		if need.Satisfies(edge.Vertex) {
+			rlog.Debugf("      => Edge '%s' satisfies subsystem %s %s\n", edge.Vertex.Type, edge.Subsystem, need)
+			need.Satisfied = true
+		}
+	}
+}
+
+// CheckSelfNeeds checks needs for the self subsystem against the vertex
+// itself. Unlike a subsystem edge, one vertex does not satisfy a need for
+// others of the same type, so every need is checked for every vertex, and
+// the needs are only updated when the vertex meets all of them.
+func CheckSelfNeeds(needs []*types.SubsystemNeed, vtx *types.Vertex) bool {
+	for _, need := range needs {
+		if !need.Satisfies(vtx) {
+			rlog.Debugf("      => Vertex '%s' does not satisfy %s\n", vtx.Type, need)
+			return false
+		}
+	}
+	for _, need := range needs {
+		need.Satisfied = true
+	}
+	return true
+}
+
+// CheckSubsystemEdge evaluates a node edge in the dominant subsystem for a
+// subsystem attribute. E.g., if the io subsystem provides shm, the needs
+// for io that shm satisfies are updated in place.
 func (m MatchType) CheckSubsystemEdge(
-	slotNeeds *types.MatchAlgorithmNeeds,
+	slotNeeds types.MatchAlgorithmNeeds,
 	edge *types.Edge,
 ) {
-
-	// Determine if our slot needs can be met
-	// Nested for loops are not great - this will be improved with a more robust graph
-	// that isn't artisinal avocado toast developed by me :)
-
 	rlog.Debugf("Looking at edge %s->%s\n", edge.Relation, edge.Vertex.Type)
 
-	updatedNeeds := types.MatchAlgorithmNeeds{}
+	// The subsystem has an edge defined here!
+	needs, ok := slotNeeds[edge.Subsystem]
+	if ok {
+		rlog.Debugf("      => Found subsystem %s edge to search\n", edge.Subsystem)
+		CheckSubsystemNeeds(needs, edge)
+	}
+}
 
-	// TODO Keep a record if all are satisfied so we stop searching
-	// earlier if this is the case on subsequent calls
-	for subsystem, needs := range *slotNeeds {
+// Init checks that options parse. Since the same algorithm is asked for
+// with different options, WithOptions makes a matcher that uses them.
+func (s MatchType) Init(options map[string]string) error {
+	_, err := ParseOptions(options)
+	return err
+}
 
-		// The subsystem has an edge defined here!
-		if subsystem == edge.Subsystem {
-			rlog.Debugf("      => Found subsystem %s edge to search\n", edge.Subsystem)
-			needs = CheckSubsystemNeeds(needs, edge)
-		}
-		updatedNeeds[subsystem] = needs
+// WithOptions returns a matcher that checks requirements with options
+func (s MatchType) WithOptions(options map[string]string) (algorithm.MatchAlgorithm, error) {
+	parsed, err := ParseOptions(options)
+	if err != nil {
+		return s, err
 	}
-	// Update the slot needs that get passed back
-	slotNeeds = &updatedNeeds
+	return MatchType{options: parsed}, nil
 }
 
-// GetResourceNeeds of a match request
-func (r *MatchEqualRequest) GetResourceNeeds(request map[string]string) map[string]bool {
-	needs := map[string]bool{}
+// Options returns the options of the matcher, to send with a request
+func (s MatchType) Options() map[string]string {
+	return s.options.Map()
+}
 
-	// Cut out early if not a match
-	match, ok := request["match"]
-	if !ok {
-		return needs
-	}
-	r.Value = match
-	field, ok := request["field"]
-	if !ok {
-		return needs
-	}
-	r.Field = field
-	if r.Field != "" && r.Value != "" {
-		// This sets the starting state that the range is not satisfied
-		needs[r.Compress()] = false
-	}
-	return needs
+// MatchOptions returns the parsed options of the matcher
+func (s MatchType) MatchOptions() Options {
+	return s.options
 }
 
-// UpdateResourceNeeds allows exposing parsing of the match interface
-// to other matchers
-func GetResourceNeeds(request map[string]string) map[string]bool {
+// A cypherRequirement can write a cypher predicate for a node
+type cypherRequirement interface {
+	Cypher(node string) string
+}
 
-	// Go through each entry and parse into a request
-	// In practice, there should only be one of these set at a time
-	req := RangeRequest{}
-	needs := req.GetResourceNeeds(request)
-	eq := MatchEqualRequest{}
-	for key, value := range eq.GetResourceNeeds(request) {
-		needs[key] = value
+// Generate cypher for the match algorithm for a resource in a slot. The
+// resource is the variable of the resource node in the query. Each
+// requirement matches its own subsystem node, since (like the memory
+// backend) different subsystem vertices can satisfy different requirements.
+// Requirements for the self subsystem are on the resource node itself, and
+// those with a path check nodes below the subsystem node.
+func (m MatchType) GenerateCypher(resource string, matchNeeds types.MatchAlgorithmNeeds) string {
+
+	// Subsystems are sorted so the query is the same each time
+	subsystems := make([]string, 0, len(matchNeeds))
+	for subsystem := range matchNeeds {
+		subsystems = append(subsystems, subsystem)
 	}
-	return needs
+	sort.Strings(subsystems)
+
+	// This will be added as a piece in a query we are building. Subsystem
+	// names come from the jobspec, so they are quoted as strings and never
+	// used in a variable name. Variables are numbered for the resource.
+	query := ""
+	count := 0
+	for _, subsystem := range subsystems {
+		for _, need := range matchNeeds[subsystem] {
+			node := fmt.Sprintf("%sSub%d", resource, count)
+			count++
+			pathReq, ok := need.Requirement.(*PathRequest)
+			if ok {
+				query += pathReq.Cypher(resource, subsystem, node)
+				continue
+			}
+			if subsystem == types.SelfSubsystem {
+				node = resource
+			}
+			predicate := "false"
+			req, ok := need.Requirement.(cypherRequirement)
+			if ok {
+				predicate = req.Cypher(node)
+			}
+			if subsystem == types.SelfSubsystem {
+				query += fmt.Sprintf("\nMATCH (%s)", resource)
+			} else {
+				query += fmt.Sprintf("\nMATCH (%s)-[:contains]-(%s:Node {subsystem: %s})", resource, node, cypherString(subsystem))
+			}
+			query += fmt.Sprintf("\nWHERE %s", predicate)
+		}
+	}
+	return query
 }
 
-// Init provides extra initialization functionality, if needed
-// The in memory database can take a backup file if desired
-func (s MatchType) Init(options map[string]string) error {
-	// If an algorithm has options, they can be set here
-	return nil
+// invalidRequest is a requirement that did not parse, and is never satisfied
+type invalidRequest struct {
+	fieldRequest
+	err error
 }
 
-// Generate cypher for the match algorithm for a specific slot
-func (m MatchType) GenerateCypher(matchNeeds *types.MatchAlgorithmNeeds) string {
+func (req *invalidRequest) Operator() string {
+	return "invalid"
+}
 
-	// This will be added as a piece in a query we are building
-	query := ""
-	for subsystemName, needs := range *matchNeeds {
+func (req *invalidRequest) Operands() map[string]string {
+	return map[string]string{"error": req.err.Error()}
+}
 
-		// k is the string to parse, we can assume since we do one query
-		// that the boolean is always false
-		for matchExpression := range needs {
-			if strings.HasPrefix(matchExpression, "match") {
-				query += MatchEqualityCypher(subsystemName, matchExpression)
+func (req *invalidRequest) Satisfies(vtx *types.Vertex) bool {
+	return false
+}
 
-			} else if strings.HasPrefix(matchExpression, "range") {
-				query += MatchRangeCypher(subsystemName, matchExpression)
-			}
+// elementString returns a string or number metadata element as a string
+func elementString(meta *metadata.Metadata, field string) (string, bool) {
+	for _, element := range meta.Elements {
+		if element.Name != field {
+			continue
+		}
+		if element.IsValue {
+			return element.Value, true
+		}
+		if element.IsInt {
+			return strconv.Itoa(int(element.IntValue)), true
+		}
+		if element.IsBool {
+			return strconv.FormatBool(element.BoolValue), true
+		}
+		switch value := element.InterfaceValue.(type) {
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64), true
+		case int:
+			return strconv.Itoa(value), true
 		}
 	}
-
-	return query
+	return "", false
 }
 
 // Add the selection algorithm to be known to rainbow
@@ -0,0 +1,255 @@
+package match
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Numeric comparisons (e.g., at least 500GB free)
+
+// NumericRequest is satisfied by a field within numeric bounds
+type NumericRequest struct {
+	fieldRequest
+
+	// Operator (gt, gte, lt, lte, between) to the value
+	Operators map[string]string
+
+	// Quantities for each comparison, parsed once
+	bounds map[string]Quantity
+}
+
+// Known numeric operators. Between is inclusive, and given as "low,high"
+var numericOperators = []string{"gt", "gte", "lt", "lte", "between"}
+
+// A unit scales a value, and values with units can only be compared
+// with values of the same dimension (e.g., bytes to bytes)
+type unit struct {
+	scale     float64
+	dimension string
+}
+
+var units = map[string]unit{
+	"":     {1, ""},
+	"B":    {1, "B"},
+	"KB":   {1e3, "B"},
+	"MB":   {1e6, "B"},
+	"GB":   {1e9, "B"},
+	"TB":   {1e12, "B"},
+	"PB":   {1e15, "B"},
+	"KiB":  {1 << 10, "B"},
+	"MiB":  {1 << 20, "B"},
+	"GiB":  {1 << 30, "B"},
+	"TiB":  {1 << 40, "B"},
+	"PiB":  {1 << 50, "B"},
+	"bps":  {1, "bps"},
+	"Kbps": {1e3, "bps"},
+	"Mbps": {1e6, "bps"},
+	"Gbps": {1e9, "bps"},
+	"Tbps": {1e12, "bps"},
+}
+
+var quantityPattern = regexp.MustCompile(`^\s*([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][-+]?[0-9]+)?)\s*([A-Za-z]*)\s*$`)
+
+// A Quantity is a value normalized to the base unit of its dimension
+type Quantity struct {
+	Value     float64
+	Dimension string
+}
+
+// ParseQuantity parses a number with an optional unit (e.g., 500GB)
+func ParseQuantity(value string) (Quantity, error) {
+	match := quantityPattern.FindStringSubmatch(value)
+	if match == nil {
+		return Quantity{}, fmt.Errorf("%s is not a number", value)
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return Quantity{}, err
+	}
+	u, ok := units[match[2]]
+	if !ok {
+		for name, known := range units {
+			if strings.EqualFold(name, match[2]) {
+				u, ok = known, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return Quantity{}, fmt.Errorf("%s has unknown unit %s", value, match[2])
+	}
+	return Quantity{Value: number * u.scale, Dimension: u.dimension}, nil
+}
+
+// parseNumeric parses a requires entry with numeric operators
+func parseNumeric(field fieldRequest, request map[string]string) (types.Requirement, error) {
+	req := &NumericRequest{fieldRequest: field, Operators: map[string]string{}}
+	for _, operator := range numericOperators {
+		value, ok := request[operator]
+		if ok {
+			req.Operators[operator] = value
+		}
+	}
+	bounds, err := req.parseBounds()
+	if err != nil {
+		return nil, err
+	}
+	req.bounds = bounds
+	return req, nil
+}
+
+func (req *NumericRequest) Operator() string {
+	return "numeric"
+}
+
+func (req *NumericRequest) Operands() map[string]string {
+	return req.Operators
+}
+
+// parseBounds returns the quantity for each comparison (gt, gte, lt, lte)
+// Between is the same as gte and lte.
+func (req *NumericRequest) parseBounds() (map[string]Quantity, error) {
+	bounds := map[string]Quantity{}
+	for operator, value := range req.Operators {
+		if operator == "between" {
+			low, high, ok := strings.Cut(value, ",")
+			if !ok {
+				return bounds, fmt.Errorf("between %s is not low,high", value)
+			}
+			lowQuantity, err := ParseQuantity(low)
+			if err != nil {
+				return bounds, err
+			}
+			highQuantity, err := ParseQuantity(high)
+			if err != nil {
+				return bounds, err
+			}
+			bounds["between-gte"] = lowQuantity
+			bounds["between-lte"] = highQuantity
+			continue
+		}
+		quantity, err := ParseQuantity(value)
+		if err != nil {
+			return bounds, err
+		}
+		bounds[operator] = quantity
+	}
+	return bounds, nil
+}
+
+// satisfies determines if a value meets every comparison
+func (req *NumericRequest) satisfies(value string) (bool, error) {
+	quantity, err := ParseQuantity(value)
+	if err != nil {
+		return false, err
+	}
+	for operator, bound := range req.bounds {
+		if !sameDimension(quantity, bound) {
+			return false, nil
+		}
+		var satisfied bool
+		switch strings.TrimPrefix(operator, "between-") {
+		case "gt":
+			satisfied = quantity.Value > bound.Value
+		case "gte":
+			satisfied = quantity.Value >= bound.Value
+		case "lt":
+			satisfied = quantity.Value < bound.Value
+		case "lte":
+			satisfied = quantity.Value <= bound.Value
+		}
+		if !satisfied {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sameDimension determines if two quantities can be compared. A number
+// without a unit is assumed to be in the base unit (e.g., bytes)
+func sameDimension(one, two Quantity) bool {
+	return one.Dimension == "" || two.Dimension == "" || one.Dimension == two.Dimension
+}
+
+// Satisfies compares the vertex metadata to numeric bounds
+func (req *NumericRequest) Satisfies(vtx *types.Vertex) bool {
+
+	// Get the field requested by the jobspec, as a string or number
+	toMatch, ok := elementString(&vtx.Metadata, req.field)
+	if !ok {
+		return false
+	}
+	rlog.Debugf("      => Found field requested for numeric match %s\n", toMatch)
+	satisfied, err := req.satisfies(toMatch)
+	if err != nil {
+		rlog.Debugf("      => Error parsing numeric value %s\n", err)
+		return false
+	}
+	return satisfied
+}
+
+// Quantities are saved to cypher backends as a normalized value and a
+// dimension, since the database cannot parse units. A field (e.g., free)
+// is saved as free_quantity and free_dimension.
+
+// QuantityProperty is the name of the property for part of a quantity field
+func QuantityProperty(field, part string) string {
+	return fmt.Sprintf("%s_%s", field, part)
+}
+
+// QuantityProperties returns the properties to save for a field value
+// If the value is not a number (or number with a unit), there are none.
+func QuantityProperties(field string, value any) map[string]any {
+	properties := map[string]any{}
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return properties
+	}
+	quantity, err := ParseQuantity(str)
+	if err != nil {
+		return properties
+	}
+	properties[QuantityProperty(field, "quantity")] = quantity.Value
+	properties[QuantityProperty(field, "dimension")] = quantity.Dimension
+	return properties
+}
+
+// Cypher writes the cypher predicate for numeric comparisons
+// This requires the quantity properties saved with the node.
+func (req *NumericRequest) Cypher(node string) string {
+	symbols := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+	quantity := cypherProperty(node, QuantityProperty(req.field, "quantity"))
+	dimension := cypherProperty(node, QuantityProperty(req.field, "dimension"))
+
+	// Bounds are sorted so the query is the same each time
+	operators := []string{}
+	for operator := range req.bounds {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+
+	predicates := []string{}
+	for _, operator := range operators {
+		bound := req.bounds[operator]
+		if bound.Dimension != "" {
+			predicates = append(predicates, fmt.Sprintf("%s IN ['%s', '']", dimension, bound.Dimension))
+		}
+		predicates = append(predicates, fmt.Sprintf(
+			"%s %s %s", quantity,
+			symbols[strings.TrimPrefix(operator, "between-")],
+			strconv.FormatFloat(bound.Value, 'f', -1, 64),
+		))
+	}
+	return strings.Join(predicates, " AND ")
+}
@@ -0,0 +1,194 @@
+package match
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/converged-computing/jsongraph-go/jsongraph/metadata"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Options change how requirements are checked. They are set for the match
+// algorithm in the rainbow config (scheduler.algorithms.match.options), and
+// sent with each request to the graph.
+type Options struct {
+
+	// Compare strings (match, not, in, not_in, regex, and where) without case
+	IgnoreCase bool
+
+	// Versions must be strict semantic versions (e.g., not 4 or v4.1)
+	StrictVersions bool
+
+	// A vertex without the field satisfies a requirement instead of failing it
+	IgnoreMissing bool
+}
+
+// Keys for options, and the values each can have (the first is the default)
+var optionValues = map[string][]string{
+	"ignore_case":   {"false", "true"},
+	"semver":        {"loose", "strict"},
+	"missing_field": {"fail", "ignore"},
+}
+
+// ParseOptions parses options for the match algorithm. An option we do not
+// know (or a value we do not know for it) is an error, since ignoring it
+// would check requirements differently than was asked for.
+func ParseOptions(options map[string]string) (Options, error) {
+	parsed := Options{}
+	keys := []string{}
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values, ok := optionValues[key]
+		if !ok {
+			return parsed, fmt.Errorf("%q is not a known option for the %s algorithm", key, matcherName)
+		}
+		value := strings.ToLower(strings.TrimSpace(options[key]))
+		if key == "ignore_case" {
+			ignore, err := strconv.ParseBool(value)
+			if err != nil {
+				return parsed, fmt.Errorf("ignore_case %s is not true or false", options[key])
+			}
+			parsed.IgnoreCase = ignore
+			continue
+		}
+		if !contains(values, value) {
+			return parsed, fmt.Errorf("%s %s is not one of %s", key, options[key], strings.Join(values, ", "))
+		}
+		switch key {
+		case "semver":
+			parsed.StrictVersions = value == "strict"
+		case "missing_field":
+			parsed.IgnoreMissing = value == "ignore"
+		}
+	}
+	return parsed, nil
+}
+
+// Map returns the options that are not the default, so the same options
+// are always written the same way
+func (o Options) Map() map[string]string {
+	options := map[string]string{}
+	if o.IgnoreCase {
+		options["ignore_case"] = "true"
+	}
+	if o.StrictVersions {
+		options["semver"] = "strict"
+	}
+	if o.IgnoreMissing {
+		options["missing_field"] = "ignore"
+	}
+	return options
+}
+
+// OptionsFor returns the options of a matcher, or the defaults if it is
+// not configured with the options of this package
+func OptionsFor(matcher algorithm.MatchAlgorithm) Options {
+	configured, ok := matcher.(interface{ MatchOptions() Options })
+	if !ok {
+		return Options{}
+	}
+	return configured.MatchOptions()
+}
+
+// equal compares two strings, without case if asked
+func (o Options) equal(one, two string) bool {
+	if o.IgnoreCase {
+		return strings.EqualFold(one, two)
+	}
+	return one == two
+}
+
+// contains determines if a value is in a list, without case if asked
+func (o Options) contains(values []string, value string) bool {
+	for _, item := range values {
+		if o.equal(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// fold returns a string to compare in cypher, lowercase if we ignore case
+func (o Options) fold(value string) string {
+	if o.IgnoreCase {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// foldAll returns strings to compare in cypher
+func (o Options) foldAll(values []string) []string {
+	folded := []string{}
+	for _, value := range values {
+		folded = append(folded, o.fold(value))
+	}
+	return folded
+}
+
+// regexFlags returns flags to start a pattern with, to ignore case if asked
+// Go and cypher (Java) regular expressions both support (?i).
+func (o Options) regexFlags() string {
+	if o.IgnoreCase {
+		return "(?i)"
+	}
+	return ""
+}
+
+// cypherField writes a field of a node as a string to compare to a value
+// Numbers are compared as strings, the same as the memory backend.
+func (o Options) cypherField(node, field string) string {
+	value := fmt.Sprintf("toString(%s)", cypherProperty(node, field))
+	if o.IgnoreCase {
+		return fmt.Sprintf("toLower(%s)", value)
+	}
+	return value
+}
+
+// newVersion parses a version, strictly if asked
+func (o Options) newVersion(value string) (*semver.Version, error) {
+	if o.StrictVersions {
+		return semver.StrictNewVersion(value)
+	}
+	return semver.NewVersion(value)
+}
+
+// missingRequest is a requirement that a vertex without the field satisfies,
+// used when missing fields are ignored
+type missingRequest struct {
+	types.Requirement
+}
+
+// Satisfies checks the requirement only if the vertex has the field
+func (req *missingRequest) Satisfies(vtx *types.Vertex) bool {
+	if !hasField(&vtx.Metadata, req.Field()) {
+		return true
+	}
+	return req.Requirement.Satisfies(vtx)
+}
+
+// Cypher writes the predicate of the requirement for a node with the field
+func (req *missingRequest) Cypher(node string) string {
+	predicate := "false"
+	inner, ok := req.Requirement.(cypherRequirement)
+	if ok {
+		predicate = inner.Cypher(node)
+	}
+	return fmt.Sprintf("(%s IS NULL OR (%s))", cypherProperty(node, req.Field()), predicate)
+}
+
+// hasField determines if metadata has a field, with a value of any type
+func hasField(meta *metadata.Metadata, field string) bool {
+	for _, element := range meta.Elements {
+		if element.Name == field {
+			return true
+		}
+	}
+	return false
+}
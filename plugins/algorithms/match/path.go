@@ -0,0 +1,202 @@
+package match
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Paths into a subsystem (e.g., a package in a spack environment)
+
+// Keys in a requires entry that choose the subsystem vertices to check.
+// The depth is how many contains edges below the connected vertex we
+// follow (or "any"), the type is the type of vertex to check, and where
+// is a list of field=value pairs the vertex must also have.
+var pathKeys = []string{"depth", "type", "where"}
+
+// anyDepth follows contains edges to the bottom of the subsystem
+const anyDepth = -1
+
+// A path selects the subsystem vertices a requirement is checked against
+type path struct {
+	vertexType string
+	depth      int
+	where      map[string]string
+
+	// Where values are compared with the options of the requirement
+	options Options
+}
+
+// PathRequest is a requirement checked against the subsystem vertices on
+// a path, and it is satisfied if any one of them satisfies it
+type PathRequest struct {
+	types.Requirement
+	path
+}
+
+// parsePath parses the path keys of a requires entry, if there are any
+func parsePath(request map[string]string, options Options) (*path, bool, error) {
+	used := false
+	for _, key := range pathKeys {
+		_, ok := request[key]
+		used = used || ok
+	}
+	if !used {
+		return nil, false, nil
+	}
+	if request["name"] == types.SelfSubsystem {
+		return nil, false, fmt.Errorf("a path (depth, type, or where) cannot be used for %s", types.SelfSubsystem)
+	}
+	p := path{vertexType: request["type"], where: map[string]string{}, options: options}
+
+	depth, ok := request["depth"]
+	if ok && depth == "any" {
+		p.depth = anyDepth
+	} else if ok {
+		value, err := strconv.Atoi(depth)
+		if err != nil || value < 0 {
+			return nil, false, fmt.Errorf("depth %s is not a number (0 or more) or any", depth)
+		}
+		p.depth = value
+	}
+
+	where, ok := request["where"]
+	if ok {
+		pairs := splitValues(where)
+		if len(pairs) == 0 {
+			return nil, false, fmt.Errorf("where requires at least one field=value")
+		}
+		for _, pair := range pairs {
+			field, value, ok := strings.Cut(pair, "=")
+			field = strings.TrimSpace(field)
+			if !ok || field == "" {
+				return nil, false, fmt.Errorf("where %s is not field=value", pair)
+			}
+			p.where[field] = strings.TrimSpace(value)
+		}
+	}
+	return &p, true, nil
+}
+
+// Operands include the path, so the requirement describes where it looks
+func (req *PathRequest) Operands() map[string]string {
+	operands := map[string]string{}
+	for key, value := range req.Requirement.Operands() {
+		operands[key] = value
+	}
+	if req.vertexType != "" {
+		operands["type"] = req.vertexType
+	}
+	if req.depth == anyDepth {
+		operands["depth"] = "any"
+	} else if req.depth > 0 {
+		operands["depth"] = strconv.Itoa(req.depth)
+	}
+	if len(req.where) > 0 {
+		pairs := []string{}
+		for _, field := range req.whereFields() {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", field, req.where[field]))
+		}
+		operands["where"] = strings.Join(pairs, ",")
+	}
+	return operands
+}
+
+// Satisfies follows contains edges from the vertex (up to the depth), and
+// checks each vertex of the type (with the where values) we find
+func (req *PathRequest) Satisfies(vtx *types.Vertex) bool {
+	seen := map[*types.Vertex]bool{vtx: true}
+	level := []*types.Vertex{vtx}
+	for depth := 0; len(level) > 0; depth++ {
+		next := []*types.Vertex{}
+		for _, candidate := range level {
+			if req.selects(candidate) && req.Requirement.Satisfies(candidate) {
+				rlog.Debugf("      => Vertex '%s' at depth %d satisfies path\n", candidate.Type, depth)
+				return true
+			}
+			if req.depth != anyDepth && depth >= req.depth {
+				continue
+			}
+			for _, edge := range candidate.Edges {
+				if edge.Relation != types.ContainsRelation || seen[edge.Vertex] {
+					continue
+				}
+				seen[edge.Vertex] = true
+				next = append(next, edge.Vertex)
+			}
+		}
+		level = next
+	}
+	return false
+}
+
+// selects determines if a vertex on the path should be checked
+func (req *PathRequest) selects(vtx *types.Vertex) bool {
+	if req.vertexType != "" && vtx.Type != req.vertexType {
+		return false
+	}
+	for field, value := range req.where {
+		found, ok := elementString(&vtx.Metadata, field)
+		if !ok || !req.options.equal(found, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// whereFields returns the fields of the where values in order
+func (req *PathRequest) whereFields() []string {
+	fields := make([]string, 0, len(req.where))
+	for field := range req.where {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Cypher writes a variable length pattern from the connected subsystem
+// node to the node to check, and a predicate for it. The pattern is
+// directed, so we only follow contains edges down into the subsystem.
+func (req *PathRequest) Cypher(resource, subsystem, node string) string {
+	target := node + "Path"
+	hops := "*0.."
+	if req.depth != anyDepth {
+		hops = fmt.Sprintf("*0..%d", req.depth)
+	}
+	properties := fmt.Sprintf("subsystem: %s", cypherString(subsystem))
+	if req.vertexType != "" {
+		properties += fmt.Sprintf(", type: %s", cypherString(req.vertexType))
+	}
+	predicates := []string{}
+	for _, field := range req.whereFields() {
+		predicates = append(predicates, fmt.Sprintf("%s = %s", req.options.cypherField(target, field), cypherString(req.options.fold(req.where[field]))))
+	}
+	predicate := "false"
+	inner, ok := req.Requirement.(cypherRequirement)
+	if ok {
+		predicate = inner.Cypher(target)
+	}
+	predicates = append(predicates, predicate)
+
+	query := fmt.Sprintf("\nMATCH (%s)-[:contains]-(%s:Node {subsystem: %s})-[:contains%s]->(%s:Node {%s})",
+		resource, node, cypherString(subsystem), hops, target, properties)
+	return query + fmt.Sprintf("\nWHERE %s", strings.Join(predicates, " AND "))
+}
+
+// Descends determines if any needs look below the connected subsystem
+// vertex, so a backend knows if it needs edges within subsystems
+func Descends(matchNeeds types.MatchAlgorithmNeeds) bool {
+	for _, needs := range matchNeeds {
+		for _, need := range needs {
+			req, ok := need.Requirement.(*PathRequest)
+			if ok && req.depth != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
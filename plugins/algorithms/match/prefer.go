@@ -0,0 +1,124 @@
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Preferences (requirements that are wanted, but not needed)
+
+// Keys in a requires entry that make it a preference. A preference does
+// not decide if a cluster matches, and the weight (1 by default) is how
+// much it counts toward the score of a cluster that does.
+var preferKeys = []string{"prefer", "weight"}
+
+// parsePreference determines if a requires entry is a preference, and its weight
+func parsePreference(request map[string]string) (bool, float64, error) {
+	prefer, ok := request["prefer"]
+	weight, hasWeight := request["weight"]
+	if !ok && !hasWeight {
+		return false, 0, nil
+	}
+	if !ok {
+		return false, 0, fmt.Errorf("weight can only be used with prefer")
+	}
+	isPreference, err := strconv.ParseBool(prefer)
+	if err != nil {
+		return false, 0, fmt.Errorf("prefer %s is not true or false", prefer)
+	}
+	if !isPreference {
+		if hasWeight {
+			return false, 0, fmt.Errorf("weight can only be used with prefer")
+		}
+		return false, 0, nil
+	}
+	value := 1.0
+	if hasWeight {
+		value, err = strconv.ParseFloat(weight, 64)
+		if err != nil || value <= 0 {
+			return false, 0, fmt.Errorf("weight %s is not a number greater than 0", weight)
+		}
+	}
+	return true, value, nil
+}
+
+// IsPreference determines if a requires entry is a preference. An entry
+// with a preference that does not parse is not, so it is never satisfied.
+func IsPreference(request map[string]string) bool {
+	isPreference, _, err := parsePreference(request)
+	return isPreference && err == nil
+}
+
+// Preferences returns a jobspec for each preference in a jobspec, where the
+// preference is required (and the other preferences are still ignored).
+// A cluster that matches one of them satisfies that preference.
+func (m MatchType) Preferences(jobspec *v1.Jobspec) ([]types.Preference, error) {
+	preferences := []types.Preference{}
+	weights := []float64{}
+	walkRequires(jobspec, func(request v1.Requires) {
+		isPreference, weight, err := parsePreference(request)
+		if isPreference && err == nil {
+			weights = append(weights, weight)
+		}
+	})
+	for i, weight := range weights {
+		required, err := copyJobspec(jobspec)
+		if err != nil {
+			return preferences, err
+		}
+
+		// The requires entries are maps, so we can update the copy in place
+		count := 0
+		walkRequires(required, func(request v1.Requires) {
+			if !IsPreference(request) {
+				return
+			}
+			if count == i {
+				delete(request, "prefer")
+				delete(request, "weight")
+			}
+			count += 1
+		})
+		preferences = append(preferences, types.Preference{Weight: weight, Jobspec: required})
+	}
+	return preferences, nil
+}
+
+// walkRequires calls a function for every requires entry of every resource,
+// in the same order each time
+func walkRequires(jobspec *v1.Jobspec, fn func(request v1.Requires)) {
+	labels := []string{}
+	for label := range jobspec.Resources {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var walk func(resource v1.Resource)
+	walk = func(resource v1.Resource) {
+		for _, request := range resource.Requires {
+			fn(request)
+		}
+		for _, with := range resource.With {
+			walk(with)
+		}
+	}
+	for _, label := range labels {
+		walk(jobspec.Resources[label])
+	}
+}
+
+// copyJobspec makes a deep copy of a jobspec
+func copyJobspec(jobspec *v1.Jobspec) (*v1.Jobspec, error) {
+	copied := v1.Jobspec{}
+	out, err := json.Marshal(jobspec)
+	if err != nil {
+		return &copied, err
+	}
+	err = json.Unmarshal(out, &copied)
+	return &copied, err
+}
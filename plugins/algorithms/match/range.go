@@ -2,6 +2,7 @@ package match
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	semver "github.com/Masterminds/semver/v3"
@@ -10,141 +11,257 @@ import (
 	"github.com/converged-computing/rainbow/pkg/types"
 )
 
-type RangeType struct{}
+// RangeRequest is satisfied by a version field between a min and max
 type RangeRequest struct {
-	Min   string
-	Max   string
-	Field string
-}
+	fieldRequest
+	Min string
+	Max string
 
-// Compress into a string to hand off to the graph for later matching
-func (req *RangeRequest) Compress() string {
+	// Constraints are parsed from the min and max once
+	constraints []*semver.Constraints
+}
 
-	value := fmt.Sprintf("range||field=%s", req.Field)
-	if req.Min != "" {
-		value = fmt.Sprintf("%s||min=%s", value, req.Min)
+// parseRange parses a requires entry with min and max
+func parseRange(field fieldRequest, request map[string]string) (types.Requirement, error) {
+	req := &RangeRequest{fieldRequest: field, Min: request["min"], Max: request["max"]}
+	bounds := []struct{ key, value, operator string }{{"min", req.Min, ">="}, {"max", req.Max, "<="}}
+	for _, bound := range bounds {
+		if bound.value == "" {
+			continue
+		}
+		_, err := field.options.newVersion(bound.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q is not a version: %s", bound.key, bound.value, err)
+		}
+		c, err := semver.NewConstraint(fmt.Sprintf("%s %s", bound.operator, bound.value))
+		if err != nil {
+			return nil, fmt.Errorf("%s %q is not a valid constraint: %s", bound.key, bound.value, err)
+		}
+		req.constraints = append(req.constraints, c)
 	}
-	if req.Max != "" {
-		value = fmt.Sprintf("%s||max=%s", value, req.Max)
+	if len(req.constraints) == 0 {
+		return nil, fmt.Errorf("range requires a min or max version")
 	}
-	return value
+	return req, nil
 }
 
-func NewRangeRequest(value string) *RangeRequest {
-	req := RangeRequest{}
-	pieces := strings.Split(value, "||")
-	for _, piece := range pieces {
-		if strings.HasPrefix(piece, "min=") {
-			req.Min = strings.ReplaceAll(piece, "min=", "")
-		} else if strings.HasPrefix(piece, "max=") {
-			req.Max = strings.ReplaceAll(piece, "max=", "")
-		} else if strings.HasPrefix(piece, "field=") {
-			req.Field = strings.ReplaceAll(piece, "field=", "")
-		}
-	}
-	return &req
+func (req *RangeRequest) Operator() string {
+	return "range"
 }
 
-// GetResourceNeeds of a range request
-func (r *RangeRequest) GetResourceNeeds(request map[string]string) map[string]bool {
-	needs := map[string]bool{}
-	for key, value := range request {
-
-		// We only know how to parse these
-		if key == "field" {
-			r.Field = value
-		} else if key == "min" {
-			r.Min = value
-		} else if key == "max" {
-			r.Max = value
-		}
+func (req *RangeRequest) Operands() map[string]string {
+	operands := map[string]string{}
+	if req.Min != "" {
+		operands["min"] = req.Min
 	}
-	// If we get here and we have a field and at LEAST
-	// one of min or max, we can add to to our needs
-	// This is a bit janky - compressing with || separators
-	if r.Field != "" && (r.Min != "" || r.Max != "") {
-		needs[r.Compress()] = false
+	if req.Max != "" {
+		operands["max"] = req.Max
 	}
-	return needs
+	return operands
 }
 
-// Determine if a range request satisfies the node field
-// If the user specifies a wonky range, this will still work,
-// but not as they expect :)
-func (req *RangeRequest) Satisfies(value string) (bool, error) {
+// Satisfies determines if the version of the vertex is in the range
+func (req *RangeRequest) Satisfies(vtx *types.Vertex) bool {
+	rlog.Debugf("      => Inspecting vertex metadata %v for range\n", vtx.Metadata.Elements)
+
+	// Get the field requested by the jobspec
+	toMatch, ok := elementString(&vtx.Metadata, req.field)
+	if !ok {
+		return false
+	}
+	rlog.Debugf("      => Found field requested for range match %s\n", toMatch)
 
 	// We already have the value for the field from the graph, now just use semver to match
-	matchVersion, err := semver.NewVersion(value)
+	version, err := req.options.newVersion(toMatch)
 	if err != nil {
 		rlog.Debugf("      => Error parsing semver for match value %s\n", err)
-		return false, err
+		return false
 	}
-	if req.Min != "" {
-		// Is the version provided greater than the min requested?
-		c, err := semver.NewConstraint(fmt.Sprintf(">= %s", req.Min))
-		if err != nil {
-			rlog.Debugf("      => Error parsing min constraint %s\n", err)
-			return false, err
-		}
-		// Check if the version meets the constraints. The a variable will be true.
-		satisfied := c.Check(matchVersion)
-		if !satisfied {
+	for _, c := range req.constraints {
+		if !c.Check(version) {
 			rlog.Debugf("      => Not satisfied\n")
-			return false, err
+			return false
+		}
+	}
+	return true
+}
+
+// Versions are saved to cypher backends as integer properties, since
+// comparing strings would order 1.10 before 1.9. A version field (e.g.,
+// version) is saved as version_major, version_minor, version_patch, and
+// version_prerelease (empty if there is none), and version_strict is true
+// if it is a strict semantic version. The identifiers of a prerelease
+// (e.g., rc and 10 for rc.10) are also saved in turn, so they can be
+// compared like the major, minor and patch. A numeric identifier is saved
+// as version_prerelease_0_number (and an empty name), and any other as
+// version_prerelease_0_name (and a number of -1), so a comparison is
+// never between an integer and a string. version_prerelease_count is the
+// number of identifiers.
+var versionParts = []string{"major", "minor", "patch", "prerelease"}
+
+// VersionProperty is the name of the property for part of a version field
+func VersionProperty(field, part string) string {
+	return fmt.Sprintf("%s_%s", field, part)
+}
+
+// prereleaseProperty is the name of the property for the number (or name)
+// of one identifier of a prerelease
+func prereleaseProperty(field string, index int, part string) string {
+	return VersionProperty(field, fmt.Sprintf("prerelease_%d_%s", index, part))
+}
+
+// prereleaseIdentifier is one part of a prerelease, a number or a name
+type prereleaseIdentifier struct {
+	number int64
+	name   string
+}
 
+// prereleaseIdentifiers splits a prerelease into its identifiers
+// A numeric identifier too large for an integer is compared as a name.
+func prereleaseIdentifiers(prerelease string) []prereleaseIdentifier {
+	identifiers := []prereleaseIdentifier{}
+	if prerelease == "" {
+		return identifiers
+	}
+	for _, part := range strings.Split(prerelease, ".") {
+		number, err := strconv.ParseInt(part, 10, 64)
+		if err == nil && number >= 0 {
+			identifiers = append(identifiers, prereleaseIdentifier{number: number})
+		} else {
+			identifiers = append(identifiers, prereleaseIdentifier{number: -1, name: part})
 		}
 	}
-	if req.Max != "" {
-		// Is the version provided less than the max requested?
-		c, err := semver.NewConstraint(fmt.Sprintf("<= %s", req.Max))
-		if err != nil {
-			rlog.Debugf("      => Error parsing max constraint %s\n", err)
-			return false, err
+	return identifiers
+}
+
+// VersionProperties returns the properties to save for a field value
+// If the value is not a version, there are none.
+func VersionProperties(field, value string) map[string]any {
+	properties := map[string]any{}
+	version, err := semver.NewVersion(value)
+	if err != nil {
+		return properties
+	}
+	values := []any{int64(version.Major()), int64(version.Minor()), int64(version.Patch()), version.Prerelease()}
+	for i, part := range versionParts {
+		properties[VersionProperty(field, part)] = values[i]
+	}
+	identifiers := prereleaseIdentifiers(version.Prerelease())
+	properties[VersionProperty(field, "prerelease_count")] = int64(len(identifiers))
+	for i, identifier := range identifiers {
+		properties[prereleaseProperty(field, i, "number")] = identifier.number
+		properties[prereleaseProperty(field, i, "name")] = identifier.name
+	}
+	_, err = semver.StrictNewVersion(value)
+	properties[VersionProperty(field, "strict")] = err == nil
+	return properties
+}
+
+// prereleaseCypher writes a predicate that the prerelease of a version is
+// after (or before) the prerelease of a bound, or equal to it. Like semver,
+// identifiers are compared in turn, numbers are before names, and when all
+// of the identifiers of one are in the other, the shorter is first.
+func prereleaseCypher(node, field, prerelease, operator string) string {
+	number := func(i int) string { return cypherProperty(node, prereleaseProperty(field, i, "number")) }
+	name := func(i int) string { return cypherProperty(node, prereleaseProperty(field, i, "name")) }
+	count := cypherProperty(node, VersionProperty(field, "prerelease_count"))
+
+	identifiers := prereleaseIdentifiers(prerelease)
+	equal := []string{}
+	choices := []string{}
+	for i, identifier := range identifiers {
+
+		// A name is after every number, and a number is before every name
+		var compare string
+		if identifier.name == "" && operator == ">" {
+			compare = fmt.Sprintf("%s > '' OR %s > %d", name(i), number(i), identifier.number)
+		} else if identifier.name == "" {
+			compare = fmt.Sprintf("(%s = '' AND %s < %d) OR %s = %d", name(i), number(i), identifier.number, count, i)
+		} else if operator == ">" {
+			compare = fmt.Sprintf("%s > %s", name(i), cypherString(identifier.name))
+		} else {
+			compare = fmt.Sprintf("%s < %s OR %s = %d", name(i), cypherString(identifier.name), count, i)
 		}
-		// Check if the version meets the constraints. The a variable will be true.
-		satisfied := c.Check(matchVersion)
-		if !satisfied {
-			rlog.Debug("      => Not satisfied")
-			return false, err
+		choices = append(choices, "("+strings.Join(append(append([]string{}, equal...), "("+compare+")"), " AND ")+")")
+
+		if identifier.name == "" {
+			equal = append(equal, fmt.Sprintf("%s = %d", number(i), identifier.number))
+		} else {
+			equal = append(equal, fmt.Sprintf("%s = %s", name(i), cypherString(identifier.name)))
 		}
 	}
-	return true, nil
-}
 
-// MatchRangeEdge matches to a range
-func MatchRangeEdge(matchExpression string, edge *types.Edge) bool {
-	rlog.Debugf("      => Found %s and inspecting edge metadata %v\n", matchExpression, edge.Vertex.Metadata.Elements)
-	req := NewRangeRequest(matchExpression)
+	// All of the identifiers are equal, and a longer prerelease is after
+	if operator != ">" {
+		equal = append(equal, fmt.Sprintf("%s = %d", count, len(identifiers)))
+	}
+	choices = append(choices, "("+strings.Join(equal, " AND ")+")")
+	return strings.Join(choices, " OR ")
+}
 
-	// Get the field requested by the jobspec
-	toMatch, err := edge.Vertex.Metadata.GetStringElement(req.Field)
+// versionCypher writes a predicate that a version field is at least (or at
+// most) a bound, comparing major, minor, and patch in turn. Like a semver
+// constraint, prereleases only satisfy a bound that has a prerelease, and
+// we return if the version must not be one.
+func versionCypher(node, field, bound, operator string) (string, bool, error) {
+	version, err := semver.NewVersion(bound)
 	if err != nil {
-		return false
+		return "", false, err
+	}
+	property := func(part string) string {
+		return cypherProperty(node, VersionProperty(field, part))
 	}
 
-	rlog.Debugf("      => Found field requested for range match %s\n", toMatch)
-	satisfied, err := req.Satisfies(toMatch)
-	if err != nil {
-		return false
+	// The last comparison (when all else is equal) includes the prerelease
+	last := fmt.Sprintf("%s %s= %d", property("patch"), operator, version.Patch())
+	if version.Prerelease() != "" {
+		prerelease := prereleaseCypher(node, field, version.Prerelease(), operator)
+		if operator == ">" {
+			last = fmt.Sprintf("%s > %d OR (%s = %d AND (%s = '' OR %s))",
+				property("patch"), version.Patch(), property("patch"), version.Patch(),
+				property("prerelease"), prerelease)
+		} else {
+			last = fmt.Sprintf("%s < %d OR (%s = %d AND %s <> '' AND (%s))",
+				property("patch"), version.Patch(), property("patch"), version.Patch(),
+				property("prerelease"), prerelease)
+		}
 	}
-	return satisfied
+	predicate := fmt.Sprintf("(%s %s %d OR (%s = %d AND (%s %s %d OR (%s = %d AND (%s)))))",
+		property("major"), operator, version.Major(), property("major"), version.Major(),
+		property("minor"), operator, version.Minor(), property("minor"), version.Minor(), last)
+	return predicate, version.Prerelease() == "", nil
 }
 
-// MatchEqualityCypher writes the lines of cypher for a match
-func MatchRangeCypher(subsystem, matchExpression string) string {
-	req := NewRangeRequest(matchExpression)
-
-	// req.Name => the subsystem
-	query := fmt.Sprintf("\n-[contains]-(%s:Node {subsystem: '%s'})", subsystem, subsystem)
+// Cypher writes the cypher predicate for a range
+// This requires the version properties saved with the node.
+func (req *RangeRequest) Cypher(node string) string {
 
 	// Need to assemble min/max, or both
-	queryPiece := "\nWHERE"
+	predicates := []string{}
+	release := false
 	if req.Min != "" {
-		queryPiece += fmt.Sprintf("%s.%s >= %d", subsystem, req.Field, req.Min)
+		predicate, isRelease, err := versionCypher(node, req.field, req.Min, ">")
+		if err != nil {
+			rlog.Debugf("      => Error parsing min constraint %s\n", err)
+			predicate = "false"
+		}
+		release = release || isRelease
+		predicates = append(predicates, predicate)
 	}
 	if req.Max != "" {
-		queryPiece += fmt.Sprintf("AND %s.%s <= %d", subsystem, req.Field, req.Max)
+		predicate, isRelease, err := versionCypher(node, req.field, req.Max, "<")
+		if err != nil {
+			rlog.Debugf("      => Error parsing max constraint %s\n", err)
+			predicate = "false"
+		}
+		release = release || isRelease
+		predicates = append(predicates, predicate)
+	}
+	if release {
+		predicates = append([]string{fmt.Sprintf("%s = ''", cypherProperty(node, VersionProperty(req.field, "prerelease")))}, predicates...)
+	}
+	if req.options.StrictVersions {
+		predicates = append([]string{fmt.Sprintf("%s = true", cypherProperty(node, VersionProperty(req.field, "strict")))}, predicates...)
 	}
-	query += queryPiece
-	return query
+	return strings.Join(predicates, " AND ")
 }
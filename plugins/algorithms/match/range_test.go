@@ -0,0 +1,234 @@
+package match
+
+// Check that the cypher predicate for a range matches the same versions as
+// semver (the memory backend), using the properties saved for a version.
+// The predicate is evaluated here, so a database is not needed.
+// go test -run TestRangeCypher ./plugins/algorithms/match
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/converged-computing/jsongraph-go/jsongraph/metadata"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+var rangeVersions = []string{
+	"0.5.1", "0.5.2", "0.5.3", "0.5.10", "1.0.0",
+	"0.5.2-1", "0.5.2-2", "0.5.2-10", "0.5.2-alpha", "0.5.2-alpha.1", "0.5.2-beta.2",
+	"0.5.2-rc", "0.5.2-rc.1", "0.5.2-rc.9", "0.5.2-rc.10", "0.5.2-rc.10.1", "0.5.2-rc.a",
+	"0.5.3-rc.1", "0.5.10-rc.2",
+}
+
+func TestRangeCypher(t *testing.T) {
+	tests := []map[string]string{
+		{"min": "0.5.2"},
+		{"max": "0.5.2"},
+		{"min": "0.5.2", "max": "0.5.10"},
+		{"min": "0.5.2-rc.9"},
+		{"min": "0.5.2-rc.10"},
+		{"max": "0.5.2-rc.9"},
+		{"max": "0.5.2-rc.10"},
+		{"min": "0.5.2-rc.9", "max": "0.5.2-rc.10"},
+		{"min": "0.5.2-rc"},
+		{"max": "0.5.2-rc"},
+		{"min": "0.5.2-2"},
+		{"max": "0.5.2-2"},
+		{"min": "0.5.2-alpha.1", "max": "0.5.2-rc.1"},
+		{"max": "0.5.10-rc.1"},
+	}
+	for _, bounds := range tests {
+		name := fmt.Sprintf("min %s max %s", bounds["min"], bounds["max"])
+		t.Run(name, func(t *testing.T) {
+			requirement, err := parseRange(fieldRequest{field: "version"}, bounds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := requirement.(*RangeRequest)
+			predicate := req.Cypher("n")
+			for _, version := range rangeVersions {
+				vertex := types.Vertex{Metadata: metadata.Metadata{}}
+				vertex.Metadata.AddElement("version", version)
+				expected := req.Satisfies(&vertex)
+				found, err := evaluateCypher(predicate, VersionProperties("version", version))
+				if err != nil {
+					t.Fatalf("%s: %s", predicate, err)
+				}
+				if found != expected {
+					t.Errorf("version %s is %t in cypher, and %t with semver", version, found, expected)
+				}
+			}
+		})
+	}
+
+	// Identifiers of a prerelease are compared as numbers, not as strings
+	requirement, err := parseRange(fieldRequest{field: "version"}, map[string]string{"min": "0.5.2-rc.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	predicate := requirement.(*RangeRequest).Cypher("n")
+	for version, expected := range map[string]bool{"0.5.2-rc.9": false, "0.5.2-rc.10": true, "0.5.2-rc.11": true} {
+		found, err := evaluateCypher(predicate, VersionProperties("version", version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != expected {
+			t.Errorf("version %s satisfies min 0.5.2-rc.10 is %t, expected %t", version, found, expected)
+		}
+	}
+}
+
+// The predicates for a range only have comparisons of node properties with
+// literals, and AND, OR, and parentheses. A missing property is null, and
+// ordering an integer and a string is an error (as it is for memgraph).
+var cypherToken = regexp.MustCompile(`\s*('(?:[^'\\]|\\.)*'|<>|<=|>=|[=<>()]|[A-Za-z0-9_.\-]+)`)
+
+type cypherParser struct {
+	tokens     []string
+	properties map[string]any
+}
+
+// evaluateCypher evaluates a predicate, and null is false
+func evaluateCypher(predicate string, properties map[string]any) (bool, error) {
+	p := cypherParser{properties: properties}
+	for _, match := range cypherToken.FindAllStringSubmatch(predicate, -1) {
+		p.tokens = append(p.tokens, match[1])
+	}
+	value, err := p.or()
+	if err != nil {
+		return false, err
+	}
+	if len(p.tokens) > 0 {
+		return false, fmt.Errorf("unexpected %v", p.tokens)
+	}
+	return value != nil && *value, nil
+}
+
+func (p *cypherParser) next() string {
+	token := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	return token
+}
+
+func (p *cypherParser) peek(token string) bool {
+	return len(p.tokens) > 0 && p.tokens[0] == token
+}
+
+// or and and use three valued logic, where nil is null
+func (p *cypherParser) or() (*bool, error) {
+	value, err := p.and()
+	for err == nil && p.peek("OR") {
+		p.next()
+		var right *bool
+		right, err = p.and()
+		if (value != nil && *value) || (right != nil && *right) {
+			value = boolValue(true)
+		} else if value == nil || right == nil {
+			value = nil
+		}
+	}
+	return value, err
+}
+
+func (p *cypherParser) and() (*bool, error) {
+	value, err := p.comparison()
+	for err == nil && p.peek("AND") {
+		p.next()
+		var right *bool
+		right, err = p.comparison()
+		if (value != nil && !*value) || (right != nil && !*right) {
+			value = boolValue(false)
+		} else if value == nil || right == nil {
+			value = nil
+		}
+	}
+	return value, err
+}
+
+func (p *cypherParser) comparison() (*bool, error) {
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	if p.peek("(") {
+		p.next()
+		value, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("expected ) at %v", p.tokens)
+		}
+		p.next()
+		return value, nil
+	}
+	if p.peek("false") || p.peek("true") {
+		return boolValue(p.next() == "true"), nil
+	}
+	if len(p.tokens) < 3 {
+		return nil, fmt.Errorf("unexpected %v", p.tokens)
+	}
+	left, operator, right := p.operand(p.next()), p.next(), p.operand(p.next())
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	switch operator {
+	case "=":
+		return boolValue(left == right), nil
+	case "<>":
+		return boolValue(left != right), nil
+	}
+	var order int
+	switch l := left.(type) {
+	case int64:
+		r, ok := right.(int64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %v %s %v", left, operator, right)
+		}
+		order = int(l - r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %v %s %v", left, operator, right)
+		}
+		order = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot order %v", left)
+	}
+	switch operator {
+	case "<":
+		return boolValue(order < 0), nil
+	case ">":
+		return boolValue(order > 0), nil
+	case "<=":
+		return boolValue(order <= 0), nil
+	case ">=":
+		return boolValue(order >= 0), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", operator)
+}
+
+// operand is the value of a property, or of a literal
+func (p *cypherParser) operand(token string) any {
+	if strings.HasPrefix(token, "n.") {
+		return p.properties[strings.TrimPrefix(token, "n.")]
+	}
+	if strings.HasPrefix(token, "'") {
+		value := strings.TrimSuffix(strings.TrimPrefix(token, "'"), "'")
+		return strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(value)
+	}
+	if token == "true" || token == "false" {
+		return token == "true"
+	}
+	number, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return token
+	}
+	return number
+}
+
+func boolValue(value bool) *bool {
+	return &value
+}
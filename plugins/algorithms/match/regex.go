@@ -0,0 +1,60 @@
+package match
+
+import (
+	"fmt"
+	"regexp"
+
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Regular expression (e.g., a module name that starts with cuda/12)
+
+// RegexRequest is satisfied by a field that matches a pattern
+type RegexRequest struct {
+	fieldRequest
+	Pattern string
+
+	// The pattern is compiled once
+	pattern *regexp.Regexp
+}
+
+// parseRegex parses a requires entry with regex
+func parseRegex(field fieldRequest, request map[string]string) (types.Requirement, error) {
+	value := request["regex"]
+	if value == "" {
+		return nil, fmt.Errorf("regex requires a pattern")
+	}
+	pattern, err := regexp.Compile(field.options.regexFlags() + value)
+	if err != nil {
+		return nil, fmt.Errorf("regex %q is not valid: %s", value, err)
+	}
+	return &RegexRequest{fieldRequest: field, Pattern: value, pattern: pattern}, nil
+}
+
+func (req *RegexRequest) Operator() string {
+	return "regex"
+}
+
+func (req *RegexRequest) Operands() map[string]string {
+	return map[string]string{"pattern": req.Pattern}
+}
+
+// Satisfies determines if the pattern is found in the field. Like grep,
+// the pattern can match part of the value unless it is anchored.
+func (req *RegexRequest) Satisfies(vtx *types.Vertex) bool {
+	toMatch, ok := elementString(&vtx.Metadata, req.field)
+	if !ok {
+		return false
+	}
+	rlog.Debugf("      => Found field requested for regex match %s\n", toMatch)
+	return req.pattern.MatchString(toMatch)
+}
+
+// Cypher writes the cypher predicate for a pattern
+// Cypher patterns must match the entire value, so we allow any
+// characters around the pattern to search like the memory backend.
+func (req *RegexRequest) Cypher(node string) string {
+	pattern := cypherString(fmt.Sprintf("%s.*(?:%s).*", req.options.regexFlags(), req.Pattern))
+	return fmt.Sprintf("toString(%s) =~ %s", cypherProperty(node, req.field), pattern)
+}
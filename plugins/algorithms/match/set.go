@@ -0,0 +1,132 @@
+package match
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// Set membership (e.g., any of these compilers, or none of these filesystems)
+
+// SetRequest is satisfied by a field that is one of (and not one of) sets of values
+type SetRequest struct {
+	fieldRequest
+
+	// Values the field must be one of (in) or must not be one of (not_in)
+	In    []string
+	NotIn []string
+}
+
+// parseSet parses a requires entry with in or not_in, comma separated lists
+func parseSet(field fieldRequest, request map[string]string) (types.Requirement, error) {
+	req := &SetRequest{fieldRequest: field}
+	for _, key := range []string{"in", "not_in"} {
+		value, ok := request[key]
+		if !ok {
+			continue
+		}
+		values := splitValues(value)
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s requires a comma separated list of values", key)
+		}
+		if key == "in" {
+			req.In = values
+		} else {
+			req.NotIn = values
+		}
+	}
+	return req, nil
+}
+
+// splitValues splits a comma separated list, ignoring empty values
+func splitValues(value string) []string {
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func (req *SetRequest) Operator() string {
+	return "set"
+}
+
+func (req *SetRequest) Operands() map[string]string {
+	operands := map[string]string{}
+	if len(req.In) > 0 {
+		operands["in"] = strings.Join(req.In, ",")
+	}
+	if len(req.NotIn) > 0 {
+		operands["not_in"] = strings.Join(req.NotIn, ",")
+	}
+	return operands
+}
+
+// Satisfies checks the vertex for set membership
+// The field must be defined - a vertex without it is not in or out.
+func (req *SetRequest) Satisfies(vtx *types.Vertex) bool {
+	toMatch, ok := elementString(&vtx.Metadata, req.field)
+	if !ok {
+		return false
+	}
+	rlog.Debugf("      => Found field requested for set match %s\n", toMatch)
+	if len(req.In) > 0 && !req.options.contains(req.In, toMatch) {
+		return false
+	}
+	return !req.options.contains(req.NotIn, toMatch)
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Cypher writes the cypher predicate for set membership
+func (req *SetRequest) Cypher(node string) string {
+
+	field := req.options.cypherField(node, req.field)
+	predicates := []string{}
+	if len(req.In) > 0 {
+		predicates = append(predicates, fmt.Sprintf("%s IN %s", field, cypherList(req.options.foldAll(req.In))))
+	}
+	if len(req.NotIn) > 0 {
+		predicates = append(predicates, fmt.Sprintf("NOT %s IN %s", field, cypherList(req.options.foldAll(req.NotIn))))
+	}
+	return strings.Join(predicates, " AND ")
+}
+
+// cypherProperty writes a property of a node for cypher. A field that is
+// not a plain name (e.g., mpi.abi) is quoted with backticks.
+func cypherProperty(node, field string) string {
+	if identifierPattern.MatchString(field) {
+		return fmt.Sprintf("%s.%s", node, field)
+	}
+	return fmt.Sprintf("%s.`%s`", node, strings.ReplaceAll(field, "`", "``"))
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// cypherString quotes a string for cypher
+func cypherString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// cypherList writes a list of strings for cypher
+func cypherList(values []string) string {
+	quoted := []string{}
+	for _, value := range values {
+		quoted = append(quoted, cypherString(value))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
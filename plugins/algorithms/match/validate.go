@@ -0,0 +1,44 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+)
+
+// ValidateJobspec checks the requires entries of every resource in a
+// jobspec, so a malformed expression is an error when the job is submit
+// instead of a requirement that is never satisfied.
+func (m MatchType) ValidateJobspec(jobspec *v1.Jobspec) error {
+	labels := []string{}
+	for label := range jobspec.Resources {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	errs := []error{}
+	var validate func(resource v1.Resource)
+	validate = func(resource v1.Resource) {
+		for i, request := range resource.Requires {
+			err := ValidateRequires(request, m.options)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("resource %s requires entry %d: %w", resource.Type, i, err))
+			}
+		}
+		for _, with := range resource.With {
+			validate(with)
+		}
+	}
+	for _, label := range labels {
+		validate(jobspec.Resources[label])
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateRequires checks that one requires entry parses with options
+func ValidateRequires(request map[string]string, options Options) error {
+	_, err := ParseRequirements(request, options)
+	return err
+}
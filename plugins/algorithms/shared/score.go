@@ -0,0 +1,60 @@
+package shared
+
+import (
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+// A Scorer is a MatchAlgorithm with preferences (requirements that are
+// wanted, but not needed) that clusters that match are scored by
+type Scorer interface {
+	Preferences(jobspec *v1.Jobspec) ([]types.Preference, error)
+}
+
+// A SatisfyFunc searches some clusters for a jobspec, and returns those that match
+type SatisfyFunc func(jobspec *v1.Jobspec, clusters []string) ([]string, error)
+
+// ScoreMatches scores clusters that match a jobspec by the preferences in
+// it they also satisfy. A cluster satisfies a preference if it matches the
+// jobspec with the preference required, and the score is the fraction of
+// the weight of all preferences that are satisfied (from 0 to 1). If the
+// jobspec has no preferences (or the matcher does not score them), there
+// are no scores.
+func ScoreMatches(
+	jobspec *v1.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	matches []string,
+	satisfy SatisfyFunc,
+) (map[string]float64, error) {
+
+	scores := map[string]float64{}
+	scorer, ok := matcher.(Scorer)
+	if !ok {
+		return scores, nil
+	}
+	preferences, err := scorer.Preferences(jobspec)
+	if err != nil || len(preferences) == 0 || len(matches) == 0 {
+		return scores, err
+	}
+	for _, cluster := range matches {
+		scores[cluster] = 0
+	}
+	total := 0.0
+	for _, preference := range preferences {
+		total += preference.Weight
+		satisfied, err := satisfy(preference.Jobspec, matches)
+		if err != nil {
+			return scores, err
+		}
+		for _, cluster := range satisfied {
+			scores[cluster] += preference.Weight
+		}
+	}
+	for cluster, score := range scores {
+		scores[cluster] = score / total
+		rlog.Debugf("  score: cluster %s satisfies %.2f of preferences\n", cluster, scores[cluster])
+	}
+	return scores, nil
+}
@@ -2,6 +2,7 @@ package shared
 
 import (
 	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
 	rlog "github.com/converged-computing/rainbow/pkg/logger"
 	"github.com/converged-computing/rainbow/pkg/types"
 	"github.com/converged-computing/rainbow/plugins/algorithms/match"
@@ -10,7 +11,9 @@ import (
 // getSlotNeeds converts the string values into SlotNeeds
 // unlike ResourceNeeds, for a slot we also have a counter for
 // different types, and the total number we have found so far.
-func GetSlotNeeds(resources *v1.Resource) *types.ResourceNeeds {
+// Requirements are checked with the options of the matcher.
+func GetSlotNeeds(resources *v1.Resource, matcher algorithm.MatchAlgorithm) *types.ResourceNeeds {
+	options := match.OptionsFor(matcher)
 
 	// type -> subsystem -> attribute -> boolean yes/no
 	matchNeeds := map[string]types.MatchAlgorithmNeeds{}
@@ -33,15 +36,18 @@ func GetSlotNeeds(resources *v1.Resource) *types.ResourceNeeds {
 		for _, needs := range resource.Requires {
 
 			// The name of the subsystem has to be under name
+			// Preferences are scored for clusters that match, and not needed
 			subsystem, ok := needs["name"]
-			if !ok {
+			if !ok || match.IsPreference(needs) {
 				continue
 			}
-			subsystemNeeds := match.GetResourceNeeds(needs)
+			// There can be more than one entry for a subsystem
+			subsystemNeeds := match.GetResourceNeeds(needs, options)
 			if len(subsystemNeeds) > 0 {
-				typeNeeds[subsystem] = subsystemNeeds
+				typeNeeds[subsystem] = append(typeNeeds[subsystem], subsystemNeeds...)
 			}
 		}
+		addMatcherNeeds(typeNeeds, resource, matcher)
 
 		if len(typeNeeds) > 0 {
 			matchNeeds[resource.Type] = typeNeeds
@@ -64,13 +70,13 @@ func GetSlotNeeds(resources *v1.Resource) *types.ResourceNeeds {
 	traverseResources(resources)
 
 	// Create new slot needs with subsystems and backup copy
-	// for cache/restore when we call refresh after finding a slot
+	// for cache/restore when we call refresh after finding a slot.
+	// Reset populates the working copy from the originals.
 	slotNeeds := &types.ResourceNeeds{
-		Subsystems:         matchNeeds,
 		SubsystemsOriginal: matchNeeds,
-		Resources:          resourceNeeds,
 		ResourcesOriginal:  resourceNeeds,
 	}
+	slotNeeds.Reset()
 
 	// Do a first check to see if they are satisfied
 	slotNeeds.AllSatisfied()
@@ -78,7 +84,8 @@ func GetSlotNeeds(resources *v1.Resource) *types.ResourceNeeds {
 }
 
 // CheckVertex ensures that subsystem needs are satisfied (and updates them)
-// and if so, includes the resource type in the count
+// and if so, includes the resource type in the count. Needs for the self
+// subsystem are checked against the vertex metadata.
 func CheckVertex(
 	slotNeeds *types.ResourceNeeds,
 	vtx *types.Vertex,
@@ -95,44 +102,98 @@ func CheckVertex(
 		return true
 	}
 
-	// First step is to check the vertex edges for subsystem matches
-	for _, edges := range vtx.Subsystems {
+	// Requirements on the vertex itself have to be met by each vertex
+	if !match.CheckSelfNeeds(typeNeeds[types.SelfSubsystem], vtx) {
+		return false
+	}
 
+	// Next we check the vertex edges for subsystem matches
+	// Any one edge can satisfy a requirement, so we check them all
+	for _, edges := range vtx.Subsystems {
 		for _, edge := range edges {
-			subsystemNeeds := typeNeeds[edge.Subsystem]
-			for attribute, isSatisfied := range match.CheckSubsystemNeeds(subsystemNeeds, edge) {
-				resourceNeeds.Subsystems[vtx.Type][edge.Subsystem][attribute] = isSatisfied
-				if !isSatisfied {
-					return false
-				} else {
-					rlog.Debugf("             Resource need for %s %s satisfied with edge %s\n", vtx.Type, attribute, edge.Vertex.Type)
-				}
-			}
+			match.CheckSubsystemNeeds(typeNeeds[edge.Subsystem], edge)
 		}
 	}
+
+	// The vertex is only a match if no requirement is left unsatisfied
+	if !typeNeeds.Satisfied() {
+		return false
+	}
 	// If we get here, the vertex has the subsystem features we want
 	// update the counts of resources
 	count -= vtx.Size
 	resourceNeeds.Resources[vtx.Type] = count
-	slotNeeds = &resourceNeeds
 	return true
 }
 
+// CheckResource determines if a vertex meets the needs for one resource of
+// its type: requirements on the vertex itself, and on its subsystem edges
+// (checked by the matcher). Unlike CheckVertex, every vertex for the
+// resource must meet them. The needs are updated in place.
+func CheckResource(
+	typeNeeds types.MatchAlgorithmNeeds,
+	vtx *types.Vertex,
+	matcher algorithm.MatchAlgorithm,
+) bool {
+
+	if !match.CheckSelfNeeds(typeNeeds[types.SelfSubsystem], vtx) {
+		return false
+	}
+	for _, edges := range vtx.Subsystems {
+		for _, edge := range edges {
+			matcher.CheckSubsystemEdge(typeNeeds, edge)
+			if typeNeeds.Satisfied() {
+				return true
+			}
+		}
+	}
+	return typeNeeds.Satisfied()
+}
+
+// SatisfyingEdges returns the subsystem edges of a vertex that satisfy
+// at least one of the needs for the vertex type. It does not update needs.
+func SatisfyingEdges(
+	slotNeeds *types.ResourceNeeds,
+	vtx *types.Vertex,
+) []*types.Edge {
+
+	satisfying := []*types.Edge{}
+	typeNeeds, ok := slotNeeds.Subsystems[vtx.Type]
+	if !ok {
+		return satisfying
+	}
+	for _, edges := range vtx.Subsystems {
+		for _, edge := range edges {
+
+			// Check the requirements directly so we see what this edge provides
+			for _, need := range typeNeeds[edge.Subsystem] {
+				if need.Satisfies(edge.Vertex) {
+					satisfying = append(satisfying, edge)
+					break
+				}
+			}
+		}
+	}
+	return satisfying
+}
+
 // getResourceNeeds flattens a resource requirement into names
 // This is intended to just check subsystem metadata for one resource
 // type (e.g., node) before we have dived into a slot
-func GetResourceNeeds(resources *v1.Resource) *types.ResourceNeeds {
+func GetResourceNeeds(resources *v1.Resource, matcher algorithm.MatchAlgorithm) *types.ResourceNeeds {
 	matchNeeds := types.MatchAlgorithmNeeds{}
+	options := match.OptionsFor(matcher)
 
 	for _, needs := range resources.Requires {
 
 		// The name of the subsystem has to be under name
 		subsystem, ok := needs["name"]
-		if !ok {
+		if !ok || match.IsPreference(needs) {
 			continue
 		}
-		matchNeeds[subsystem] = match.GetResourceNeeds(needs)
+		matchNeeds[subsystem] = append(matchNeeds[subsystem], match.GetResourceNeeds(needs, options)...)
 	}
+	addMatcherNeeds(matchNeeds, resources, matcher)
 
 	// Since the type is relevant here, organize the matchNeeds by the one type
 	needs := map[string]types.MatchAlgorithmNeeds{resources.Type: matchNeeds}
@@ -142,3 +203,19 @@ func GetResourceNeeds(resources *v1.Resource) *types.ResourceNeeds {
 	slotNeeds.AreResourcesSatisfied()
 	return slotNeeds
 }
+
+// addMatcherNeeds adds needs the matcher has for a resource in addition to
+// its requires entries (e.g., from a compatibility artifact)
+func addMatcherNeeds(
+	typeNeeds types.MatchAlgorithmNeeds,
+	resource *v1.Resource,
+	matcher algorithm.MatchAlgorithm,
+) {
+	needer, ok := matcher.(algorithm.NeedsAlgorithm)
+	if !ok {
+		return
+	}
+	for subsystem, needs := range needer.ResourceNeeds(resource) {
+		typeNeeds[subsystem] = append(typeNeeds[subsystem], needs...)
+	}
+}
@@ -0,0 +1,809 @@
+package cypher
+
+// A rainbow backend for graph databases that speak openCypher
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"strings"
+
+	"context"
+	"fmt"
+
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend"
+	rspec "github.com/converged-computing/rainbow/pkg/jobspec"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/pkg/utils"
+	"github.com/converged-computing/rainbow/plugins/algorithms/match"
+	"github.com/converged-computing/rainbow/plugins/algorithms/shared"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"google.golang.org/grpc"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+)
+
+// Nodes and edges are created in batches of this size
+var batchSize = 1000
+
+// Queries to read and write the state of a cluster, given the $name of its
+// subsystem node (or $names, to read more than one)
+var (
+	stateQuery    = "MATCH (n:Subsystem {name: $name}) RETURN n.state AS state"
+	setStateQuery = "MATCH (n:Subsystem {name: $name}) SET n.state = $state"
+	statesQuery   = "MATCH (n:Subsystem) WHERE n.name IN $names RETURN n.name AS name, n.state AS state"
+)
+
+// Queries to add and delete subsystems, given the $name of the subsystem
+// node. Nodes and edges are created from a batch of $nodes or $edges, and
+// the nodes of a subsystem are deleted with a query from the dialect.
+var (
+	subsystemQuery         = "MATCH (n:Subsystem {name: $name}) RETURN n.name AS name"
+	createSubsystemQuery   = "CREATE (n:Subsystem {type: $type, name: $name, cluster: $cluster})"
+	createNodesQuery       = "UNWIND $nodes AS n CREATE (v:Node) SET v = n"
+	clusterSubsystemsQuery = "MATCH (n:Subsystem) WHERE n.name = $name OR n.cluster = $cluster RETURN n.name AS name"
+	deleteSubsystemQuery   = "MATCH (n:Subsystem {name: $name}) DETACH DELETE n"
+	createEdgesQuery       = fmt.Sprintf(
+		"UNWIND $edges AS e MATCH (a:Node {name: e.source}), (b:Node {name: e.target}) CREATE (a)-[r:%s]->(b)",
+		types.ContainsRelation,
+	)
+)
+
+// Backend is a rainbow graph backend for a database that speaks openCypher
+// The dialect provides what is different between databases
+type Backend struct {
+	dialect  Dialect
+	host     string
+	database string
+	username string
+	password string
+
+	// Shared driver, created by Init, and what runs queries with it
+	driver neo4j.DriverWithContext
+	runner runner
+}
+
+// New creates a backend for a dialect, with its default connection
+func New(dialect Dialect) *Backend {
+	b := &Backend{
+		dialect:  dialect,
+		host:     dialect.URI(),
+		username: dialect.Username(),
+		password: "chocolate-cookies",
+	}
+	b.runner = driverRunner{backend: b}
+	return b
+}
+
+func (b *Backend) Name() string {
+	return b.dialect.Name()
+}
+
+func (b *Backend) Description() string {
+	return b.dialect.Description()
+}
+
+// AddCluster adds a new cluster to the graph
+// Name is the name of the cluster
+func (b *Backend) AddCluster(
+	name string,
+	nodes *jgf.JsonGraph,
+	subsystem string,
+) error {
+	// Add a cluster subsystem
+	if subsystem == "" {
+		subsystem = types.DefaultDominantSubsystem
+	}
+	return b.AddSubsystem(name, nodes, subsystem)
+}
+
+// UpdateState updates the state of a cluster in the database
+// The state is saved as json on the cluster subsystem node, so types are
+// preserved, and new values are merged with the old ones.
+func (b *Backend) UpdateState(
+	name string,
+	payload string,
+) error {
+	// Load state into interface
+	state := types.ClusterState{}
+	err := json.Unmarshal([]byte(payload), &state)
+	if err != nil {
+		return err
+	}
+
+	// Read the current state and write the merged state in one transaction
+	ctx := context.Background()
+	params := map[string]any{"name": graph.GetNamespacedName(types.DefaultDominantSubsystem, name)}
+	return b.runner.write(ctx, func(tx transaction) error {
+		rows, err := tx.run(ctx, stateQuery, params)
+		if err != nil {
+			return err
+		}
+		if len(rows) != 1 {
+			return fmt.Errorf("cluster %s does not exist", name)
+		}
+		current, err := parseState(rows[0]["state"])
+		if err != nil {
+			return err
+		}
+
+		// We always update old values
+		for key, value := range state {
+			rlog.Debugf("Updating state %s to %v\n", key, value)
+			current[key] = value
+		}
+		out, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		params["state"] = string(out)
+		_, err = tx.run(ctx, setStateQuery, params)
+		return err
+	})
+}
+
+// parseState loads state saved as json on a subsystem node
+// A cluster that has not had an update has an empty state
+func parseState(value any) (types.ClusterState, error) {
+	state := types.ClusterState{}
+	saved, ok := value.(string)
+	if !ok || saved == "" {
+		return state, nil
+	}
+	err := json.Unmarshal([]byte(saved), &state)
+	return state, err
+}
+
+// Capacity is not supported for this backend, so we don't know
+func (b *Backend) Capacity(
+	jobspec *v1.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	names []string,
+) (map[string]int32, error) {
+	rlog.Debugf("The %s backend does not support capacity queries\n", b.dialect.Name())
+	return map[string]int32{}, nil
+}
+
+// GetStates for a list of clusters
+func (b *Backend) GetStates(names []string) (map[string]types.ClusterState, error) {
+	states := map[string]types.ClusterState{}
+
+	// Cluster subsystem nodes are namespaced, e.g., cluster-keebler
+	lookup := map[string]string{}
+	for _, name := range names {
+		lookup[graph.GetNamespacedName(types.DefaultDominantSubsystem, name)] = name
+	}
+	subsystems := utils.Keys(lookup)
+
+	ctx := context.Background()
+	params := map[string]any{"names": subsystems}
+	rows, err := b.runner.query(ctx, statesQuery, params)
+	if err != nil {
+		return states, err
+	}
+	for _, values := range rows {
+		subsystem, _ := values["name"].(string)
+		state, err := parseState(values["state"])
+		if err != nil {
+			return states, err
+		}
+		states[lookup[subsystem]] = state
+	}
+
+	// Only error if the cluster isn't known
+	for _, name := range names {
+		if _, ok := states[name]; !ok {
+			return states, fmt.Errorf("cluster %s does not exist", name)
+		}
+	}
+	return states, nil
+}
+
+// AddSusbsystem to the graph
+// Name is the name of the cluster that the subsystem belongs to
+// Nodes and edges are created in batches (with parameters) in one transaction
+func (b *Backend) AddSubsystem(
+	name string,
+	nodes *jgf.JsonGraph,
+	subsystem string,
+) error {
+
+	// Let's be pedantic - no clusters allowed without nodes or edges
+	_, _, err := graph.ValidateNodes(nodes)
+	if err != nil {
+		return err
+	}
+
+	// We will need the dominant (containment) subsystem name for external edges
+	// e.g., cluster-keebler-<some-id>
+	domName := graph.GetNamespacedName("cluster", name)
+	cluster := name
+
+	// Names are always prefixed with subsystem, e.g,
+	// cluster-keebler
+	// io-keebler
+	name = fmt.Sprintf("%s-%s", subsystem, name)
+
+	// Prepare node properties, keeping a temporary lookup
+	vertices := []map[string]any{}
+	lookup := map[string]string{}
+	for nid, node := range nodes.Graph.Nodes {
+
+		// Defining a lookup name means that we keep a direct index to the node in
+		// the subsystem lookup. We do this for edges between subsystems so
+		// they are always namespaced
+		// - name with subsystem, cluster name, and original id is indexed
+		//   e.g., cluster-keebler-0
+		lookupName := graph.GetNamespacedName(name, nid)
+		vertices = append(vertices, nodeProperties(node, lookupName, subsystem, name))
+
+		// This stores the original JGF id so we can reference it for internal edge
+		lookup[nid] = lookupName
+	}
+
+	// Count dominant vertices references
+	count := 0
+	relationships := []map[string]any{}
+	for _, edge := range nodes.Graph.Edges {
+
+		// We are currently just saving one direction "x contains y"
+		if edge.Relation != types.ContainsRelation {
+			continue
+		}
+
+		// Two cases:
+		// 1. the src is in the dominant subsystem
+		// 2. The src is not, and both node are defined in the graph here
+		subIdx1, ok1 := lookup[edge.Source]
+		subIdx2, ok2 := lookup[edge.Target]
+
+		// Case 1: both are in the subsystem graph
+		// This says "subsystem resource in node"
+		if ok1 && ok2 {
+			relationships = append(relationships, map[string]any{"source": subIdx1, "target": subIdx2})
+
+		} else if ok2 {
+
+			// Case 2: the src is in the dominant subsystem
+			// We need the namespaced name for the dom lookup
+			// This says "dominant subsystem node conatains subsystem resource"
+			lookupName := graph.GetNamespacedName(domName, edge.Source)
+			rlog.Debugf("Adding dominant subsystem edge for %s to %s in %s\n", lookupName, subIdx2, subsystem)
+			count += 1
+			relationships = append(relationships, map[string]any{"source": lookupName, "target": subIdx2})
+		} else {
+			return fmt.Errorf("edge %s->%s is not internal, and not connected to the dominant subsystem", edge.Source, edge.Target)
+		}
+	}
+
+	ctx := context.Background()
+	err = b.runner.write(ctx, func(tx transaction) error {
+
+		// Check that we don't have it already - a subsystem (or cluster) can only be added once
+		// type likely isn't needed, but it would allow us to filter down quickly to an entire kind
+		// of subsystem if needed
+		params := map[string]any{"name": name, "type": subsystem, "cluster": cluster}
+		rows, err := tx.run(ctx, subsystemQuery, params)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			return fmt.Errorf("subsystem '%s' with type '%s' already exists", name, subsystem)
+		}
+
+		// A subsystem that is not dominant needs the cluster
+		if subsystem != types.DefaultDominantSubsystem {
+			rows, err = tx.run(ctx, subsystemQuery, map[string]any{"name": domName})
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return fmt.Errorf("cluster %s does not exist", cluster)
+			}
+		}
+		_, err = tx.run(ctx, createSubsystemQuery, params)
+		if err != nil {
+			return err
+		}
+
+		// Create nodes, and then edges between them
+		rlog.Debugf("Creating %d nodes for subsystem %s\n", len(vertices), subsystem)
+		for start := 0; start < len(vertices); start += batchSize {
+			end := start + batchSize
+			if end > len(vertices) {
+				end = len(vertices)
+			}
+			_, err = tx.run(ctx, createNodesQuery, map[string]any{"nodes": vertices[start:end]})
+			if err != nil {
+				return err
+			}
+		}
+		for start := 0; start < len(relationships); start += batchSize {
+			end := start + batchSize
+			if end > len(relationships) {
+				end = len(relationships)
+			}
+			_, err = tx.run(ctx, createEdgesQuery, map[string]any{"edges": relationships[start:end]})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Printf("We have made a %s (subsystem %s) with %d vertices, with %d connections to the dominant!", b.dialect.Name(), subsystem, len(vertices), count)
+	} else {
+		log.Printf("We have made a %s (subsystem %s) with %d vertices", b.dialect.Name(), subsystem, len(vertices))
+	}
+	return nil
+}
+
+// nodeProperties are saved for a node, including all of its metadata
+// Values that cannot be properties (e.g., maps) are saved as json.
+// The name, subsystem, and owner (the subsystem it belongs to) are
+// always set by rainbow.
+func nodeProperties(node jgf.Node, name, subsystem, owner string) map[string]any {
+	properties := map[string]any{}
+
+	// Metadata only serializes to its original types as json
+	values := map[string]any{}
+	out, err := json.Marshal(node.Metadata)
+	if err == nil {
+		json.Unmarshal(out, &values)
+	}
+	for key, value := range values {
+		properties[key] = propertyValue(value)
+	}
+
+	// Versions are also saved as parts, to compare ranges
+	for key, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		for part, partValue := range match.VersionProperties(key, str) {
+			_, exists := properties[part]
+			if !exists {
+				properties[part] = partValue
+			}
+		}
+	}
+
+	// Numbers (and numbers with units) are saved normalized, to compare bounds
+	for key, value := range values {
+		for part, partValue := range match.QuantityProperties(key, value) {
+			_, exists := properties[part]
+			if !exists {
+				properties[part] = partValue
+			}
+		}
+	}
+
+	// Currently the type, size, and unit always have a value
+	resource := types.NewResource(node)
+	properties["type"] = resource.Type
+	properties["size"] = resource.Size
+	properties["unit"] = resource.Unit
+	properties["name"] = name
+	properties["subsystem"] = subsystem
+	properties["owner"] = owner
+	return properties
+}
+
+// propertyValue converts a json value to a property value
+func propertyValue(value any) any {
+	switch v := value.(type) {
+	case string, bool:
+		return v
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v)
+		}
+		return v
+	case []any:
+		// Lists of strings can be saved as is
+		strs := []string{}
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				break
+			}
+			strs = append(strs, str)
+		}
+		if len(strs) == len(v) {
+			return strs
+		}
+	}
+	out, _ := json.Marshal(value)
+	return string(out)
+}
+
+// RegisterService does a test connection. The database has its own
+// authentication, so the cluster validator is not used.
+func (b *Backend) RegisterService(s *grpc.Server, validate backend.ClusterValidator) error {
+
+	// This is akin to calling init
+	log.Printf("🧠️ Registering %s graph database...\n", b.dialect.Name())
+
+	// Do a test connection
+	ctx := context.Background()
+	err := b.driver.VerifyConnectivity(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Prepare to have subsystems (a cluster is also a subsystem)
+	// Index syntax is different between databases
+	session := b.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: b.database})
+	defer session.Close(ctx)
+
+	// Run index queries via implicit auto-commit transaction
+	for _, index := range b.dialect.Indexes() {
+		_, err = session.Run(ctx, index, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCluster removes the cluster, and all of its subsystems
+func (b *Backend) DeleteCluster(name string) error {
+	domName := graph.GetNamespacedName(types.DefaultDominantSubsystem, name)
+	params := map[string]any{"name": domName, "cluster": name}
+
+	ctx := context.Background()
+	return b.runner.write(ctx, func(tx transaction) error {
+		rows, err := tx.run(ctx, subsystemQuery, params)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return fmt.Errorf("cluster %s does not exist", name)
+		}
+
+		// Subsystems know their cluster, and the dominant is one of them
+		rows, err = tx.run(ctx, clusterSubsystemsQuery, params)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			subsystem, _ := row["name"].(string)
+			err = b.deleteSubsystem(ctx, tx, subsystem)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteSubsystem deletes nodes that belong to a subsystem, and then the subsystem
+func (b *Backend) deleteSubsystem(ctx context.Context, tx transaction, name string) error {
+	params := map[string]any{"name": name}
+	_, err := tx.run(ctx, b.dialect.DeleteNodes(), params)
+	if err != nil {
+		return err
+	}
+	_, err = tx.run(ctx, deleteSubsystemQuery, params)
+	return err
+}
+
+// DeleteSubsystem removes it from the graph
+// Without the dominant subsystem there is no cluster, so it is deleted too
+func (b *Backend) DeleteSubsystem(name, subsystem string) error {
+	if subsystem == types.DefaultDominantSubsystem {
+		return b.DeleteCluster(name)
+	}
+
+	// Subsystem names are prefixed with the subsystem, e.g., cluster-keebler
+	name = fmt.Sprintf("%s-%s", subsystem, name)
+	params := map[string]any{"name": name}
+
+	ctx := context.Background()
+	return b.runner.write(ctx, func(tx transaction) error {
+		rows, err := tx.run(ctx, subsystemQuery, params)
+		if err != nil {
+			return err
+		}
+
+		// Subsystem does not exist!
+		if len(rows) == 0 {
+			return fmt.Errorf("subsystem '%s' with type '%s' does not exist", name, subsystem)
+		}
+		return b.deleteSubsystem(ctx, tx, name)
+	})
+}
+
+// Satisfies - determine what clusters satisfy a jobspec request
+// Since this is called from the client function, it's technically
+// running from the client (not from the server). See examples
+// in comments below. Explanations for mismatches are not supported.
+// The database has its own authentication, so cluster credentials
+// are not checked here, but we only search the clusters they name.
+func (b *Backend) Satisfies(
+	jobspec *v1.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	explain bool,
+	clusters map[string]string,
+) (*types.SatisfyResult, error) {
+
+	matches, err := b.satisfies(jobspec, matcher, explain, clusters)
+	if err != nil {
+		return matches, err
+	}
+
+	// Count ranges are checked at their smallest sizes, and then molded
+	// for each cluster that matches
+	if rspec.HasRanges(jobspec) {
+		for _, name := range matches.Clusters {
+			sizes, err := rspec.Mold(jobspec, func(molded *v1.Jobspec) (bool, error) {
+				result, err := b.satisfies(molded, matcher, false, map[string]string{name: clusters[name]})
+				return len(result.Clusters) > 0, err
+			})
+			if err != nil {
+				return matches, err
+			}
+			matches.Sizes[name] = sizes
+		}
+	}
+
+	// Matches are scored by the preferences they satisfy, if there are any
+	matches.Scores, err = shared.ScoreMatches(jobspec, matcher, matches.Clusters, func(required *v1.Jobspec, names []string) ([]string, error) {
+		tokens := map[string]string{}
+		for _, name := range names {
+			tokens[name] = clusters[name]
+		}
+		result, err := b.satisfies(required, matcher, false, tokens)
+		return result.Clusters, err
+	})
+	return matches, err
+}
+
+// satisfies finds the clusters that satisfy a jobspec request, without scores
+func (b *Backend) satisfies(
+	jobspec *v1.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	explain bool,
+	clusters map[string]string,
+) (*types.SatisfyResult, error) {
+
+	// Note that this algorithm is different in that it skips
+	// hieuristics (checking totals) because we minimize queries
+	// to the graph.
+	matches := types.NewSatisfyResult()
+	jobspec = rspec.AtMinimum(jobspec)
+	if explain {
+		rlog.Debugf("The %s backend does not support explaining mismatches\n", b.dialect.Name())
+	}
+
+	// Some matchers read the jobspec (e.g., an artifact it references)
+	matcher, err := algorithm.ForJobspec(matcher, jobspec)
+	if err != nil {
+		return matches, err
+	}
+
+	// Get resources that need scheduling from the jobspec
+	// This is a map[string]Resource{} that may or may not have type slot
+	resources := jobspec.GetScheduledNamedSlots()
+
+	// Show the JobSpec for debugging
+	out, err := jobspec.JobspecToYaml()
+	if err == nil {
+		rlog.Debugf(" jobspec: %s\n", out)
+	}
+
+	// Each schedulable unit will get a separate query
+	var query string
+
+	// Parse into resource structure and update the query appropriately
+	var updateQuery func(resource v1.Resource, resourceTypes []string, lastSeen string) error
+	updateQuery = func(resource v1.Resource, resourceTypes []string, lastSeen string) error {
+
+		// Keep track of seen types. When we parse a slot, we need to start
+		// at where we left off
+		seenTypes := []string{}
+
+		// Get local resource needs (subsystem edges), add to query
+		resourceNeeds := shared.GetResourceNeeds(&resource, matcher)
+
+		for _, resourceType := range resourceTypes {
+			subsystemNeeds, exists := resourceNeeds.Subsystems[resourceType]
+
+			// Recursive call needs to start where we left off
+			seenTypes = append(seenTypes, resourceType)
+
+			// We need to keep track of the containment edge name
+			// This is how we count slots at the end
+			resourceEdgeName := fmt.Sprintf("%sEdge", resourceType)
+
+			// Here is an example asking for a subsystem attribute
+			// MATCH (cluster:Node {subsystem: 'cluster', type: 'cluster'})
+			//	-[r0:contains]-(rack:Node {subsystem: 'cluster', type: 'rack'})
+			//	-[r1:contains]-(node:Node {subsystem: 'cluster', type: 'node'})
+			// MATCH (node)-[:contains]-(nodeSub0:Node {subsystem: 'io'})
+			// WHERE toString(nodeSub0.type) = 'shm'
+			// MATCH (node) -[r2:contains]-(socket:Node {subsystem: 'cluster', type: 'socket'})
+			//	-[r3:contains]-(core:Node {subsystem: 'cluster', type: 'core'})
+			// RETURN *
+			// If we have a last scene, it needs to be a new MATCH
+			newQuery := fmt.Sprintf("-[%s:contains]-(%s:Node {subsystem: 'cluster', type: '%s'})", resourceEdgeName, resourceType, resourceType)
+			if lastSeen != "" {
+				newQuery = fmt.Sprintf("\nMATCH (%s) %s", lastSeen, newQuery)
+			} else {
+				newQuery = "\n" + newQuery
+			}
+			query += newQuery
+
+			// If we hit a subsystem, we need to define last seen, because it will start a new
+			// MATCH expression for the next time.
+			if exists {
+				query += matcher.GenerateCypher(resourceType, subsystemNeeds)
+				lastSeen = resourceType
+			} else {
+				lastSeen = ""
+			}
+		}
+
+		// Keep going until we find a slot. When replicas != 0
+		// we have found a slot.
+		if resource.Replicas != 0 {
+			if resource.With != nil {
+				for _, with := range resource.With {
+
+					// Child functions should start at resources we haven't parsed yet
+					// We need the last seen type to add after a WHERE
+					lastSeen = ""
+					if len(seenTypes) > 0 {
+						lastSeen = seenTypes[len(seenTypes)-1]
+					}
+
+					// These are remaining resources we need to parse over
+					remainingResources := utils.Diff(resourceTypes, seenTypes)
+					err := updateQuery(with, remainingResources, lastSeen)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	// Then get results for that, and we need to pass in a scecond query
+	// Right now do a query for each schedulable slot
+	// Not sure if these can be combined into one
+	var topSlot graph.SlotCount
+	for _, resource := range resources {
+
+		// We need to go through the structure of the graph. If
+		// there are no subsystem needs, we match all. Otherwise
+		// we also look for an edge to the subsytem
+		resourceTypes := []string{"rack", "node", "socket", "core"}
+
+		query = "MATCH (cluster:Node {subsystem: 'cluster', type: 'cluster'})"
+
+		// Only search the clusters we are asked for. The cluster node
+		// belongs to the cluster subsystem (e.g., cluster-keebler)
+		if len(clusters) > 0 {
+			query += "\nWHERE cluster.owner IN $clusters"
+			query += "\nMATCH (cluster)"
+		}
+		updateQuery(resource, resourceTypes, "")
+
+		// When we get here, we are at a slot, and can just add to the query the
+		// requirements of counts
+		totals := graph.ExtractResourceSlots(jobspec)
+
+		// This is the query I'm going for now - not sure if entirely correct
+		// Maybe someone can help me more on these some day when they have bandwidth
+		// MATCH (cluster:Node {subsystem: 'cluster', type: 'cluster'})
+		// -[rackEdge:contains]-(rack:Node {subsystem: 'cluster', type: 'rack'})
+		// -[nodeEdge:contains]-(node:Node {subsystem: 'cluster', type: 'node'})
+		// MATCH (node)-[:contains]-(nodeSub0:Node {subsystem: 'io'})
+		// WHERE toString(nodeSub0.type) = 'shm'
+		// MATCH (node) -[socketEdge:contains]-(socket:Node {subsystem: 'cluster', type: 'socket'})
+		// -[coreEdge:contains]-(core:Node {subsystem: 'cluster', type: 'core'})
+		// WITH node,count(coreEdge) as cores_count
+		// WHERE cores_count >= 3
+		// RETURN node, cores_count
+
+		for _, slotCount := range totals {
+			query += fmt.Sprintf("\nWITH cluster,%s,count(distinct %sEdge) as %s_count", slotCount.Parent, slotCount.Name, slotCount.Name)
+			query += fmt.Sprintf("\nWHERE %s_count >= %d", slotCount.Name, slotCount.Members)
+		}
+
+		// This assumes the return statement is the highest level of the slot
+		topSlot = totals[0]
+		query += fmt.Sprintf("\nRETURN cluster,%s, %s_count", topSlot.Parent, topSlot.Name)
+		rlog.Debugf("\n%s\n", query)
+	}
+
+	// Do the query
+	ctx := context.Background()
+	owners := []string{}
+	for _, name := range utils.Keys(clusters) {
+		owners = append(owners, graph.GetNamespacedName(types.DefaultDominantSubsystem, name))
+	}
+	params := map[string]any{"clusters": owners}
+	rows, err := b.runner.query(ctx, query, params)
+	if err != nil {
+		return matches, err
+	}
+
+	// Keep a count of matches per cluster
+	lookup := map[string]int32{}
+
+	// Print the node results
+	for _, row := range rows {
+		clusterName := row["cluster"].(neo4j.Node).Props["name"].(string)
+
+		// This gets rid of the prefix
+		originalName := strings.Replace(clusterName, "cluster-", "", 1)
+
+		// And the suffix
+		parts := strings.Split(originalName, "-")
+		originalName = strings.Join(parts[0:len(parts)-1], "-")
+		_, ok := lookup[originalName]
+		if !ok {
+			lookup[originalName] = 0
+		}
+		lookup[originalName] += 1
+	}
+
+	// Keep matches that we have minimum slot count
+	for cluster, count := range lookup {
+		if count >= topSlot.Count {
+			matches.Clusters = append(matches.Clusters, cluster)
+		}
+	}
+	rlog.Debugf("\nMatches: %s\n", matches.Clusters)
+	return matches, nil
+}
+
+// Init provides extra initialization functionality
+// We check credentials here
+func (b *Backend) Init(
+	options map[string]string,
+) error {
+	host, username, password := b.host, b.username, b.password
+
+	// Warning: this assumes one client running with one graph host
+	uri, ok := options["memoryHost"]
+	if ok {
+		b.host = uri
+	}
+	user, ok := options["username"]
+	if ok {
+		b.username = user
+	}
+	pw, ok := options["password"]
+	if ok {
+		b.password = pw
+	}
+
+	// The driver keeps a pool of connections, and is shared by all requests.
+	// Init can be called again (the client does for each submit), so we keep
+	// the driver unless the connection changed, and then close the old one.
+	if b.driver != nil {
+		if b.host == host && b.username == username && b.password == password {
+			return nil
+		}
+		err := b.driver.Close(context.Background())
+		b.driver = nil
+		if err != nil {
+			return err
+		}
+	}
+	var err error
+	b.driver, err = neo4j.NewDriverWithContext(b.host, neo4j.BasicAuth(b.username, b.password, b.database))
+	return err
+}
@@ -0,0 +1,21 @@
+package cypher
+
+// A Dialect is what is different between openCypher databases
+// The backend is otherwise shared, and a database registers it with
+// its dialect (see the neo4j and memgraph backends).
+type Dialect interface {
+
+	// Name and description of the backend
+	Name() string
+	Description() string
+
+	// Default connection URI and username, both can be set with Init
+	URI() string
+	Username() string
+
+	// Queries to create indexes, run when the service is registered
+	Indexes() []string
+
+	// Query to delete the nodes owned by a subsystem, given $name
+	DeleteNodes() string
+}
@@ -0,0 +1,62 @@
+package cypher
+
+// Check that the driver is shared when the backend is initialized again,
+// and closed when the connection changes. The driver does not connect until
+// it is used, so a database is not needed.
+// go test -run TestInitDriver ./plugins/backends/cypher
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestInitDriver(t *testing.T) {
+	b := New(standInDialect{})
+	options := map[string]string{"memoryHost": "bolt://127.0.0.1:1", "username": "neo4j", "password": "chocolate-cookies"}
+	err := b.Init(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := b.driver
+	t.Cleanup(func() { b.driver.Close(context.Background()) })
+
+	// The same connection keeps the driver (and its pool)
+	err = b.Init(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.driver != driver {
+		t.Errorf("the driver was created again for the same connection")
+	}
+	if closed(driver) {
+		t.Errorf("the driver was closed for the same connection")
+	}
+
+	// A new password needs a new driver, and the old one is closed
+	options["password"] = "oatmeal-cookies"
+	err = b.Init(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.driver == driver {
+		t.Errorf("the driver was kept for a new password")
+	}
+	if !closed(driver) {
+		t.Errorf("the old driver was not closed")
+	}
+	if closed(b.driver) {
+		t.Errorf("the new driver is closed")
+	}
+}
+
+// closed determines if a driver was closed, since it will not create sessions
+func closed(driver neo4j.DriverWithContext) bool {
+	ctx := context.Background()
+	session := driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer session.Close(ctx)
+	_, err := session.BeginTransaction(ctx)
+	return err != nil && strings.Contains(err.Error(), "closed driver")
+}
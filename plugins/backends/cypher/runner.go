@@ -0,0 +1,78 @@
+package cypher
+
+import (
+	"context"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Records are the rows a query returns, by the names in its RETURN
+type records []map[string]any
+
+// A transaction runs queries that are committed together
+type transaction interface {
+	run(ctx context.Context, query string, params map[string]any) (records, error)
+}
+
+// A runner runs queries for the backend. It is the driver, except in tests
+// that use a stand-in for the database.
+type runner interface {
+
+	// query runs one query in its own transaction
+	query(ctx context.Context, query string, params map[string]any) (records, error)
+
+	// write runs work that writes in one transaction
+	write(ctx context.Context, work func(tx transaction) error) error
+}
+
+// driverRunner runs queries with the driver of a backend, created by Init
+type driverRunner struct {
+	backend *Backend
+}
+
+func (r driverRunner) query(ctx context.Context, query string, params map[string]any) (records, error) {
+	result, err := neo4j.ExecuteQuery(
+		ctx, r.backend.driver, query, params,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase(r.backend.database),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return toRecords(result.Records), nil
+}
+
+func (r driverRunner) write(ctx context.Context, work func(tx transaction) error) error {
+	session := r.backend.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: r.backend.database})
+	defer session.Close(ctx)
+	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		return nil, work(driverTransaction{tx: tx})
+	})
+	return err
+}
+
+// driverTransaction runs queries in a transaction of the driver
+type driverTransaction struct {
+	tx neo4j.ManagedTransaction
+}
+
+func (t driverTransaction) run(ctx context.Context, query string, params map[string]any) (records, error) {
+	result, err := t.tx.Run(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	collected, err := result.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toRecords(collected), nil
+}
+
+// toRecords converts records from the driver to maps
+func toRecords(collected []*neo4j.Record) records {
+	rows := records{}
+	for _, record := range collected {
+		rows = append(rows, record.AsMap())
+	}
+	return rows
+}
@@ -0,0 +1,204 @@
+package cypher
+
+// A stand-in for the database, so the backend can be tested without one.
+// It knows the queries the backend runs, and not cypher.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// The dialect for the stand-in
+type standInDialect struct{}
+
+func (d standInDialect) Name() string        { return "stand-in" }
+func (d standInDialect) Description() string { return "stand-in for an openCypher database" }
+func (d standInDialect) URI() string         { return "" }
+func (d standInDialect) Username() string    { return "" }
+func (d standInDialect) Indexes() []string   { return []string{} }
+func (d standInDialect) DeleteNodes() string { return "MATCH (n:Node {owner: $name}) DETACH DELETE n" }
+
+// A standIn keeps Subsystem and Node nodes in memory, by name, and the
+// contains edges between nodes. Writes are only kept if the work of the
+// transaction succeeds.
+type standIn struct {
+	graph standInGraph
+}
+
+type standInGraph struct {
+	subsystems map[string]map[string]any
+	nodes      map[string]map[string]any
+	edges      map[string][]string
+}
+
+// newStandIn creates a stand-in with (empty) clusters already added
+func newStandIn(clusters ...string) *standIn {
+	s := &standIn{graph: standInGraph{
+		subsystems: map[string]map[string]any{},
+		nodes:      map[string]map[string]any{},
+		edges:      map[string][]string{},
+	}}
+	for _, cluster := range clusters {
+		name := graph.GetNamespacedName(types.DefaultDominantSubsystem, cluster)
+		s.graph.subsystems[name] = map[string]any{"type": types.DefaultDominantSubsystem, "name": name, "cluster": cluster}
+	}
+	return s
+}
+
+// newTestBackend creates a backend that runs queries with a stand-in
+func newTestBackend(s *standIn) *Backend {
+	return &Backend{dialect: standInDialect{}, runner: s}
+}
+
+// copy returns a copy of the graph for a transaction to change
+func (g standInGraph) copy() standInGraph {
+	copied := standInGraph{
+		subsystems: map[string]map[string]any{},
+		nodes:      map[string]map[string]any{},
+		edges:      map[string][]string{},
+	}
+	for name, properties := range g.subsystems {
+		copied.subsystems[name] = map[string]any{}
+		for key, value := range properties {
+			copied.subsystems[name][key] = value
+		}
+	}
+	for name, properties := range g.nodes {
+		copied.nodes[name] = properties
+	}
+	for name, targets := range g.edges {
+		copied.edges[name] = append([]string{}, targets...)
+	}
+	return copied
+}
+
+// query runs the states query, or a satisfy query. The stand-in does not
+// search, and returns the cluster for every node in the clusters asked for.
+func (s *standIn) query(ctx context.Context, query string, params map[string]any) (records, error) {
+	rows := records{}
+	if query == statesQuery {
+		for _, name := range params["names"].([]string) {
+			properties, ok := s.graph.subsystems[name]
+			if ok {
+				rows = append(rows, map[string]any{"name": name, "state": properties["state"]})
+			}
+		}
+		return rows, nil
+	}
+	if strings.HasPrefix(query, "MATCH (cluster:Node") {
+		clusters := map[string]bool{}
+		for _, owner := range params["clusters"].([]string) {
+			clusters[owner] = true
+		}
+		for _, properties := range s.graph.nodes {
+			owner := properties["owner"].(string)
+			if properties["type"] != "node" || (strings.Contains(query, "$clusters") && !clusters[owner]) {
+				continue
+			}
+			for _, cluster := range s.graph.nodes {
+				if cluster["owner"] == owner && cluster["type"] == "cluster" {
+					rows = append(rows, map[string]any{"cluster": neo4j.Node{Props: cluster}})
+				}
+			}
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("stand-in does not know query %q", query)
+}
+
+func (s *standIn) write(ctx context.Context, work func(tx transaction) error) error {
+	tx := &standInTransaction{graph: s.graph.copy(), dialect: standInDialect{}}
+	err := work(tx)
+	if err == nil {
+		s.graph = tx.graph
+	}
+	return err
+}
+
+// A standInTransaction works on a copy of the graph
+type standInTransaction struct {
+	graph   standInGraph
+	dialect Dialect
+}
+
+func (t *standInTransaction) run(ctx context.Context, query string, params map[string]any) (records, error) {
+	name, _ := params["name"].(string)
+	properties, ok := t.graph.subsystems[name]
+	switch query {
+	case stateQuery:
+		if !ok {
+			return records{}, nil
+		}
+		return records{{"state": properties["state"]}}, nil
+
+	case setStateQuery:
+		if ok {
+			properties["state"] = params["state"]
+		}
+		return records{}, nil
+
+	case subsystemQuery:
+		if !ok {
+			return records{}, nil
+		}
+		return records{{"name": name}}, nil
+
+	case createSubsystemQuery:
+		t.graph.subsystems[name] = map[string]any{"type": params["type"], "name": name, "cluster": params["cluster"]}
+		return records{}, nil
+
+	case createNodesQuery:
+		for _, node := range params["nodes"].([]map[string]any) {
+			t.graph.nodes[node["name"].(string)] = node
+		}
+		return records{}, nil
+
+	case createEdgesQuery:
+		for _, edge := range params["edges"].([]map[string]any) {
+			source, target := edge["source"].(string), edge["target"].(string)
+			_, sourceExists := t.graph.nodes[source]
+			_, targetExists := t.graph.nodes[target]
+			if sourceExists && targetExists {
+				t.graph.edges[source] = append(t.graph.edges[source], target)
+			}
+		}
+		return records{}, nil
+
+	case clusterSubsystemsQuery:
+		rows := records{}
+		for subsystem, properties := range t.graph.subsystems {
+			if subsystem == name || properties["cluster"] == params["cluster"] {
+				rows = append(rows, map[string]any{"name": subsystem})
+			}
+		}
+		return rows, nil
+
+	case t.dialect.DeleteNodes():
+		for node, properties := range t.graph.nodes {
+			if properties["owner"] == name {
+				delete(t.graph.nodes, node)
+				delete(t.graph.edges, node)
+			}
+		}
+		for source, targets := range t.graph.edges {
+			kept := []string{}
+			for _, target := range targets {
+				if _, exists := t.graph.nodes[target]; exists {
+					kept = append(kept, target)
+				}
+			}
+			t.graph.edges[source] = kept
+		}
+		return records{}, nil
+
+	case deleteSubsystemQuery:
+		delete(t.graph.subsystems, name)
+		return records{}, nil
+	}
+	return nil, fmt.Errorf("stand-in does not know query %q", query)
+}
@@ -0,0 +1,95 @@
+package cypher
+
+// Check that cluster state is saved, merged and read back with the cypher
+// backend, with a stand-in for the database.
+// go test ./plugins/backends/cypher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+func TestState(t *testing.T) {
+	b := newTestBackend(newStandIn("keebler", "spack"))
+
+	steps := []struct {
+		name     string
+		cluster  string
+		payload  string
+		wantErr  bool
+		expected map[string]types.ClusterState
+	}{
+		{
+			name:     "no state before an update",
+			expected: map[string]types.ClusterState{"keebler": {}, "spack": {}},
+		},
+		{
+			name:     "update saves types",
+			cluster:  "keebler",
+			payload:  `{"cost-per-node": 12, "ready": true, "region": "east"}`,
+			expected: map[string]types.ClusterState{"keebler": {"cost-per-node": 12.0, "ready": true, "region": "east"}, "spack": {}},
+		},
+		{
+			name:     "update merges with saved state",
+			cluster:  "keebler",
+			payload:  `{"cost-per-node": 10, "max-jobs": 4}`,
+			expected: map[string]types.ClusterState{"keebler": {"cost-per-node": 10.0, "ready": true, "region": "east", "max-jobs": 4.0}, "spack": {}},
+		},
+		{
+			name:     "update one cluster",
+			cluster:  "spack",
+			payload:  `{"ready": false}`,
+			expected: map[string]types.ClusterState{"keebler": {"cost-per-node": 10.0, "ready": true, "region": "east", "max-jobs": 4.0}, "spack": {"ready": false}},
+		},
+		{
+			name:    "update unknown cluster",
+			cluster: "unknown",
+			payload: `{"ready": true}`,
+			wantErr: true,
+		},
+		{
+			name:    "update with invalid payload",
+			cluster: "keebler",
+			payload: `{"ready": `,
+			wantErr: true,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.cluster != "" {
+				err := b.UpdateState(step.cluster, step.payload)
+				if step.wantErr {
+					if err == nil {
+						t.Fatalf("expected an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			states, err := b.GetStates([]string{"keebler", "spack"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(states, step.expected) {
+				t.Errorf("states are %v, expected %v", states, step.expected)
+			}
+		})
+	}
+
+	// Only clusters that are asked for are returned, and unknown is an error
+	states, err := b.GetStates([]string{"spack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 {
+		t.Errorf("states are %v, expected only spack", states)
+	}
+	_, err = b.GetStates([]string{"keebler", "unknown"})
+	if err == nil {
+		t.Errorf("expected an error for states of an unknown cluster")
+	}
+}
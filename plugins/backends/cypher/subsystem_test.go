@@ -0,0 +1,151 @@
+package cypher
+
+// Check that clusters and subsystems are added and deleted with the cypher
+// backend, and that satisfy only searches the clusters it is asked for,
+// with a stand-in for the database.
+// go test ./plugins/backends/cypher
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+)
+
+func TestSubsystems(t *testing.T) {
+	examples := filepath.Join("..", "..", "..", "docs", "examples", "scheduler")
+	nodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examples, "cluster-nodes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioNodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examples, "cluster-io-subsystem.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(examples, "jobspec-constraint.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher := algorithm.GetOrFail("match")
+
+	s := newStandIn()
+	b := newTestBackend(s)
+
+	// Each step changes the graph, and then we check the subsystems,
+	// and the number of nodes each owns
+	steps := []struct {
+		name       string
+		change     func() error
+		wantErr    bool
+		subsystems map[string]int
+	}{
+		{
+			name:       "add cluster",
+			change:     func() error { return b.AddCluster("keebler", &nodes, "") },
+			subsystems: map[string]int{"cluster-keebler": 44},
+		},
+		{
+			name:       "add cluster again",
+			change:     func() error { return b.AddCluster("keebler", &nodes, "") },
+			wantErr:    true,
+			subsystems: map[string]int{"cluster-keebler": 44},
+		},
+		{
+			name:       "add subsystem to unknown cluster",
+			change:     func() error { return b.AddSubsystem("unknown", &ioNodes, "io") },
+			wantErr:    true,
+			subsystems: map[string]int{"cluster-keebler": 44},
+		},
+		{
+			name:       "add subsystem",
+			change:     func() error { return b.AddSubsystem("keebler", &ioNodes, "io") },
+			subsystems: map[string]int{"cluster-keebler": 44, "io-keebler": 6},
+		},
+		{
+			name:       "add another cluster",
+			change:     func() error { return b.AddCluster("spack", &nodes, "") },
+			subsystems: map[string]int{"cluster-keebler": 44, "io-keebler": 6, "cluster-spack": 44},
+		},
+		{
+			name:       "delete subsystem",
+			change:     func() error { return b.DeleteSubsystem("keebler", "io") },
+			subsystems: map[string]int{"cluster-keebler": 44, "cluster-spack": 44},
+		},
+		{
+			name:       "delete subsystem again",
+			change:     func() error { return b.DeleteSubsystem("keebler", "io") },
+			wantErr:    true,
+			subsystems: map[string]int{"cluster-keebler": 44, "cluster-spack": 44},
+		},
+		{
+			name: "delete cluster with a subsystem",
+			change: func() error {
+				err := b.AddSubsystem("keebler", &ioNodes, "io")
+				if err != nil {
+					return err
+				}
+				return b.DeleteCluster("keebler")
+			},
+			subsystems: map[string]int{"cluster-spack": 44},
+		},
+		{
+			name:       "delete unknown cluster",
+			change:     func() error { return b.DeleteCluster("keebler") },
+			wantErr:    true,
+			subsystems: map[string]int{"cluster-spack": 44},
+		},
+		{
+			name:       "delete dominant subsystem",
+			change:     func() error { return b.DeleteSubsystem("spack", "cluster") },
+			subsystems: map[string]int{},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			err := step.change()
+			if step.wantErr && err == nil {
+				t.Errorf("expected an error")
+			}
+			if !step.wantErr && err != nil {
+				t.Fatal(err)
+			}
+			subsystems := map[string]int{}
+			for name := range s.graph.subsystems {
+				subsystems[name] = 0
+			}
+			for _, properties := range s.graph.nodes {
+				subsystems[properties["owner"].(string)]++
+			}
+			if !reflect.DeepEqual(subsystems, step.subsystems) {
+				t.Errorf("subsystems are %v, expected %v", subsystems, step.subsystems)
+			}
+		})
+		if step.name == "add another cluster" {
+			testSatisfy(t, b, jobspec, matcher)
+		}
+	}
+}
+
+// testSatisfy checks that satisfy only searches the clusters it is asked for
+func testSatisfy(t *testing.T, b *Backend, jobspec *js.Jobspec, matcher algorithm.MatchAlgorithm) {
+	tests := []struct {
+		clusters map[string]string
+		expected []string
+	}{
+		{clusters: map[string]string{"keebler": ""}, expected: []string{"keebler"}},
+		{clusters: map[string]string{"spack": ""}, expected: []string{"spack"}},
+		{clusters: map[string]string{"unknown": ""}},
+	}
+	for _, test := range tests {
+		result, err := b.Satisfies(jobspec, matcher, false, test.clusters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Clusters) != len(test.expected) || (len(test.expected) > 0 && !reflect.DeepEqual(result.Clusters, test.expected)) {
+			t.Errorf("satisfy for %v matched %v, expected %v", test.clusters, result.Clusters, test.expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys for credentials on each request. The graph token gives
+// access to the entire graph, and each cluster token (name:token) gives
+// read access to one cluster.
+const (
+	tokenKey        = "authorization"
+	clusterTokenKey = "cluster-token"
+)
+
+var errDenied = status.Error(codes.Unauthenticated, "request denied")
+
+// errNoCredentials is returned for a graph server that would accept every
+// request, and was not asked to be insecure
+var errNoCredentials = errors.New("a graph token (or cluster tokens) is required to serve the graph, unless it is insecure")
+
+// tokenCredentials adds the graph token to each request
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{tokenKey: t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// withClusterTokens adds cluster tokens to the context of a request
+func withClusterTokens(ctx context.Context, clusters map[string]string) context.Context {
+	for name, token := range clusters {
+		ctx = metadata.AppendToOutgoingContext(ctx, clusterTokenKey, name+":"+token)
+	}
+	return ctx
+}
+
+// access is what a caller is allowed to see in the graph
+type access struct {
+	all      bool
+	clusters map[string]bool
+}
+
+// allowed determines if the caller can see a cluster
+func (a *access) allowed(cluster string) bool {
+	return a.all || a.clusters[cluster]
+}
+
+// filter returns the subset of clusters the caller can see
+func (a *access) filter(clusters []string) []string {
+	allowed := []string{}
+	for _, cluster := range clusters {
+		if a.allowed(cluster) {
+			allowed = append(allowed, cluster)
+		}
+	}
+	return allowed
+}
+
+// candidates returns the clusters to search for a request. An empty
+// request asks for every cluster the caller can see.
+func (a *access) candidates(requested []string) []string {
+	if a.all {
+		return requested
+	}
+	if len(requested) == 0 {
+		for cluster := range a.clusters {
+			requested = append(requested, cluster)
+		}
+		sort.Strings(requested)
+		return requested
+	}
+	return a.filter(requested)
+}
+
+// authorize checks the credentials of a request. The graph token gives
+// access to all clusters, and otherwise cluster tokens are checked with
+// the validator. Only a server that was asked to be insecure is open.
+func (s MemoryServer) authorize(ctx context.Context) (*access, error) {
+	token, validate := s.token, s.validate
+	result := &access{clusters: map[string]bool{}}
+	if s.insecure {
+		result.all = true
+		return result, nil
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, errDenied
+	}
+	// The graph token is compared in constant time, so the time a check
+	// takes does not tell how much of a guess was right
+	values := md.Get(tokenKey)
+	if token != "" && len(values) > 0 && subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) == 1 {
+		result.all = true
+		return result, nil
+	}
+	if validate != nil {
+		for _, value := range md.Get(clusterTokenKey) {
+			name, clusterToken, found := strings.Cut(value, ":")
+			if found && validate(name, clusterToken) {
+				result.clusters[name] = true
+			}
+		}
+	}
+	if len(result.clusters) == 0 {
+		return nil, errDenied
+	}
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package memory
+
+// Check which clusters a request to the graph can access, for the graph
+// token, cluster tokens, and a server without credentials.
+// go test -run TestAuthorize ./plugins/backends/memory
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/converged-computing/rainbow/pkg/certs"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAuthorize(t *testing.T) {
+	validate := func(cluster, token string) bool {
+		return token == cluster+"-token"
+	}
+	tests := []struct {
+		name     string
+		server   MemoryServer
+		metadata []string
+		all      bool
+		clusters []string
+		denied   bool
+	}{
+		{
+			name:   "no credentials is denied",
+			server: MemoryServer{},
+			denied: true,
+		},
+		{
+			name:     "no credentials is denied with a token",
+			server:   MemoryServer{},
+			metadata: []string{tokenKey, "graph-token"},
+			denied:   true,
+		},
+		{
+			name:   "insecure is open",
+			server: MemoryServer{insecure: true},
+			all:    true,
+		},
+		{
+			name:     "graph token",
+			server:   MemoryServer{token: "graph-token"},
+			metadata: []string{tokenKey, "graph-token"},
+			all:      true,
+		},
+		{
+			name:     "wrong graph token",
+			server:   MemoryServer{token: "graph-token"},
+			metadata: []string{tokenKey, "graph-tokem"},
+			denied:   true,
+		},
+		{
+			name:   "missing graph token",
+			server: MemoryServer{token: "graph-token"},
+			denied: true,
+		},
+		{
+			name:     "cluster tokens",
+			server:   MemoryServer{token: "graph-token", validate: validate},
+			metadata: []string{clusterTokenKey, "keebler:keebler-token", clusterTokenKey, "spack:spack-token", clusterTokenKey, "self:wrong"},
+			clusters: []string{"keebler", "spack"},
+		},
+		{
+			name:     "cluster tokens without a validator",
+			server:   MemoryServer{token: "graph-token"},
+			metadata: []string{clusterTokenKey, "keebler:keebler-token"},
+			denied:   true,
+		},
+		{
+			name:     "wrong cluster tokens",
+			server:   MemoryServer{validate: validate},
+			metadata: []string{clusterTokenKey, "keebler:spack-token"},
+			denied:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.metadata != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(test.metadata...))
+			}
+			allowed, err := test.server.authorize(ctx)
+			if test.denied {
+				if err == nil {
+					t.Fatalf("expected the request to be denied")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			clusters := []string{}
+			for cluster := range allowed.clusters {
+				clusters = append(clusters, cluster)
+			}
+			sort.Strings(clusters)
+			if allowed.all != test.all || (len(test.clusters) > 0 && !reflect.DeepEqual(clusters, test.clusters)) {
+				t.Errorf("access is all %t to %v, expected all %t to %v", allowed.all, clusters, test.all, test.clusters)
+			}
+		})
+	}
+}
+
+func TestGraphServerCredentials(t *testing.T) {
+	_, err := NewGraphServer(&certs.Certificate{}, "", "", false)
+	if err == nil {
+		t.Errorf("a graph server without a token should not be created unless it is insecure")
+	}
+	server, err := NewGraphServer(&certs.Certificate{}, "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Stop()
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+)
+
+var (
+	// Maximum number of cluster results to keep in the satisfy cache
+	defaultCacheSize = 4096
+)
+
+// A SatisfyCache is a bounded LRU cache of match results for a cluster.
+// Each entry remembers the generation of the cluster it was computed for,
+// and is stale (a miss) when the cluster generation changes.
+type SatisfyCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	lock    sync.Mutex
+}
+
+type cacheEntry struct {
+	key        string
+	generation uint64
+	result     *MatchResult
+}
+
+// NewSatisfyCache creates a cache that holds up to size results
+func NewSatisfyCache(size int) *SatisfyCache {
+	return &SatisfyCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// cacheKey hashes the jobspec with the matcher name and options. A jobspec
+// (and options) serializes to json with fields and map keys in a consistent
+// order, so identical requests hash the same.
+func cacheKey(jobspec *js.Jobspec, matcher algorithm.MatchAlgorithm) (string, error) {
+	out, err := json.Marshal(jobspec)
+	if err != nil {
+		return "", err
+	}
+	options, err := json.Marshal(algorithm.Options(matcher))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%x", matcher.Name(), sha256.Sum256(append(out, options...))), nil
+}
+
+// Get returns a result for a cluster, if we have one for the current generation
+func (c *SatisfyCache) Get(clusterName, key string, generation uint64) (*MatchResult, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[clusterName+"/"+key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+
+	// The cluster has changed since we saved this, so remove it
+	if entry.generation != generation {
+		c.order.Remove(element)
+		delete(c.entries, entry.key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.result, true
+}
+
+// Add saves a result for a cluster, evicting the least recently used if full
+func (c *SatisfyCache) Add(clusterName, key string, generation uint64, result *MatchResult) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key = clusterName + "/" + key
+	element, ok := c.entries[key]
+	if ok {
+		entry := element.Value.(*cacheEntry)
+		entry.generation = generation
+		entry.result = result
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, generation: generation, result: result})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of results in the cache
+func (c *SatisfyCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
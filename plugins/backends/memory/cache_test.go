@@ -0,0 +1,164 @@
+package memory
+
+// Check that the satisfy cache evicts the least recently used result when
+// it is full, and that a cached result is not used after its cluster changes.
+// go test -run Cache ./plugins/backends/memory
+
+import (
+	"path/filepath"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+const (
+	cacheCluster   = "keebler"
+	cacheSubsystem = "io"
+)
+
+func TestSatisfyCache(t *testing.T) {
+	result := &MatchResult{IsMatch: true}
+
+	// Each step adds a result, or gets one and expects a hit or miss
+	// The cache holds two results
+	steps := []struct {
+		name       string
+		add        bool
+		cluster    string
+		generation uint64
+		hit        bool
+	}{
+		{name: "empty cache", cluster: "a", generation: 1},
+		{name: "add a", add: true, cluster: "a", generation: 1},
+		{name: "add b", add: true, cluster: "b", generation: 1},
+		{name: "get a", cluster: "a", generation: 1, hit: true},
+		{name: "get b", cluster: "b", generation: 1, hit: true},
+		{name: "get a again", cluster: "a", generation: 1, hit: true},
+		{name: "add c evicts b", add: true, cluster: "c", generation: 1},
+		{name: "get b after eviction", cluster: "b", generation: 1},
+		{name: "get a after eviction", cluster: "a", generation: 1, hit: true},
+		{name: "get c", cluster: "c", generation: 1, hit: true},
+		{name: "get a for a new generation", cluster: "a", generation: 2},
+		{name: "get a stale result is removed", cluster: "a", generation: 1},
+		{name: "add a for a new generation", add: true, cluster: "a", generation: 2},
+		{name: "get a for the new generation", cluster: "a", generation: 2, hit: true},
+	}
+	cache := NewSatisfyCache(2)
+	for _, step := range steps {
+		if step.add {
+			cache.Add(step.cluster, "key", step.generation, result)
+			if cache.Len() > 2 {
+				t.Fatalf("%s: cache has %d results, expected at most 2", step.name, cache.Len())
+			}
+			continue
+		}
+		_, hit := cache.Get(step.cluster, "key", step.generation)
+		if hit != step.hit {
+			t.Errorf("%s: hit is %t, expected %t", step.name, hit, step.hit)
+		}
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	examples := filepath.Join("..", "..", "..", "docs", "examples", "scheduler")
+	nodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examples, "cluster-nodes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioNodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examples, "cluster-io-subsystem.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(examples, "jobspec-io.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := jobspec.JobspecToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher := algorithm.GetOrFail("match")
+
+	// Each change must give the cluster a new generation
+	tests := []struct {
+		name      string
+		subsystem bool
+		change    func(g *Graph) error
+	}{
+		{
+			name: "register again",
+			change: func(g *Graph) error {
+				err := g.DeleteCluster(cacheCluster)
+				if err != nil {
+					return err
+				}
+				return g.LoadClusterNodes(cacheCluster, &nodes, "")
+			},
+		},
+		{
+			name: "add subsystem",
+			change: func(g *Graph) error {
+				return g.LoadSubsystemNodes(cacheCluster, &ioNodes, cacheSubsystem)
+			},
+		},
+		{
+			name:      "delete subsystem",
+			subsystem: true,
+			change: func(g *Graph) error {
+				return g.DeleteSubsystem(cacheCluster, cacheSubsystem)
+			},
+		},
+		{
+			name: "update state",
+			change: func(g *Graph) error {
+				return g.UpdateState(cacheCluster, &types.ClusterState{"cost-per-node": 12})
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewGraph()
+			err := g.LoadClusterNodes(cacheCluster, &nodes, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.subsystem {
+				err = g.LoadSubsystemNodes(cacheCluster, &ioNodes, cacheSubsystem)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The first request is searched, and the second is cached
+			satisfyCached(t, g, payload, matcher, false)
+			satisfyCached(t, g, payload, matcher, true)
+
+			err = test.change(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			satisfyCached(t, g, payload, matcher, false)
+			satisfyCached(t, g, payload, matcher, true)
+		})
+	}
+}
+
+// satisfyCached makes a satisfy request and checks the cache counters of
+// the cluster for a hit (or a miss)
+func satisfyCached(t *testing.T, g *Graph, payload string, matcher algorithm.MatchAlgorithm, hit bool) {
+	metrics := &g.Clusters[cacheCluster].DominantSubsystem().Metrics
+	hits, misses := metrics.CacheHits, metrics.CacheMisses
+	_, err := g.Satisfies(payload, matcher, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit && (metrics.CacheHits != hits+1 || metrics.CacheMisses != misses) {
+		t.Errorf("expected a cache hit, counters are %d hits and %d misses", metrics.CacheHits, metrics.CacheMisses)
+	}
+	if !hit && (metrics.CacheHits != hits || metrics.CacheMisses != misses+1) {
+		t.Errorf("expected a cache miss, counters are %d hits and %d misses", metrics.CacheHits, metrics.CacheMisses)
+	}
+}
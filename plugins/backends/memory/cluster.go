@@ -1,6 +1,8 @@
 package memory
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
 	"log"
 	"sync"
@@ -23,6 +25,12 @@ type ClusterGraph struct {
 	// The dominant subsystem is a lookup in the subsystem map
 	// It defaults to nodes (node resources)
 	dominantSubsystem string
+
+	// Skip subtrees that do not have needed resources during search
+	prune bool
+
+	// Changes when the cluster changes, so cached results are not used
+	generation uint64
 }
 
 // GetState of the cluster
@@ -85,6 +93,9 @@ func (g *ClusterGraph) LoadClusterNodes(
 	}
 	log.Printf("We have made an in memory graph (subsystem %s) with %d vertices!", subsystem, ss.CountVertices())
 
+	// Aggregate counts below each vertex so the search can prune
+	ss.CountDescendants()
+
 	// Show metrics
 	ss.Metrics.Show()
 	return nil
@@ -108,10 +119,66 @@ func NewClusterGraph(name string, domSubsystem string) *ClusterGraph {
 		subsystem:         subsystems,
 		dominantSubsystem: types.DefaultDominantSubsystem,
 		State:             types.ClusterState{},
+		prune:             true,
 	}
 	return g
 }
 
+// A clusterBackup is what we save of a cluster graph. The generation is
+// not saved, since a restored cluster gets a new one.
+type clusterBackup struct {
+	Name              string
+	State             map[string]interface{}
+	Subsystems        map[string]*Subsystem
+	DominantSubsystem string
+	Prune             bool
+}
+
+// Vertex metadata and cluster state are generic values (e.g., from json)
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// GobEncode saves the cluster graph, including unexported fields, to a backup
+func (g *ClusterGraph) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(clusterBackup{
+		Name:              g.Name,
+		State:             g.State,
+		Subsystems:        g.subsystem,
+		DominantSubsystem: g.dominantSubsystem,
+		Prune:             g.prune,
+	})
+	return buf.Bytes(), err
+}
+
+// GobDecode restores the cluster graph from a backup. Descendants are
+// counted again, so pruning does not depend on what was saved.
+func (g *ClusterGraph) GobDecode(data []byte) error {
+	backup := clusterBackup{}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&backup)
+	if err != nil {
+		return err
+	}
+	g.Name = backup.Name
+	g.State = backup.State
+	g.subsystem = backup.Subsystems
+	g.dominantSubsystem = backup.DominantSubsystem
+	g.prune = backup.Prune
+	if g.State == nil {
+		g.State = types.ClusterState{}
+	}
+	if g.dominantSubsystem == "" {
+		g.dominantSubsystem = types.DefaultDominantSubsystem
+	}
+	dom := g.DominantSubsystem()
+	if dom != nil {
+		dom.CountDescendants()
+	}
+	return nil
+}
+
 // GetMetrics for a named subsystem, defaulting to dominant
 func (g *ClusterGraph) GetMetrics(subsystem string) Metrics {
 	subsystem = g.getSubsystem(subsystem)
@@ -119,6 +186,21 @@ func (g *ClusterGraph) GetMetrics(subsystem string) Metrics {
 	return ss.Metrics
 }
 
+// deleteSubsystem removes a subsystem, and the edges to it
+// from the dominant subsystem
+func (g *ClusterGraph) deleteSubsystem(subsystem string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.subsystem, subsystem)
+	dom := g.DominantSubsystem()
+	if dom == nil {
+		return
+	}
+	for _, vtx := range dom.Vertices {
+		delete(vtx.Subsystems, subsystem)
+	}
+}
+
 // LoadSubsystemNodes into the cluster
 func (g *ClusterGraph) LoadSubsystemNodes(
 	nodes *jgf.JsonGraph,
@@ -0,0 +1,52 @@
+package memory_test
+
+// Check that the memory backend passes the conformance checks that every
+// graph backend can run, with its service in this process.
+// go test -run TestConformance ./plugins/backends/memory
+
+import (
+	"net"
+	"testing"
+
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend/backendtest"
+	"github.com/converged-computing/rainbow/plugins/backends/memory"
+	"google.golang.org/grpc"
+
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+const conformanceToken = "backendtest"
+
+func TestConformance(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphDB := memory.MemoryGraph{}
+	err = graphDB.Init(map[string]string{"host": listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { graphDB.Init(map[string]string{"host": ":50051"}) })
+
+	// Satisfy requests send the same token for every cluster
+	server := grpc.NewServer()
+	err = graphDB.RegisterService(server, func(name, token string) bool {
+		return token == conformanceToken
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	err = backendtest.TestBackend(graphDB, backendtest.Options{
+		Examples: "../../../docs/examples",
+		Matcher:  algorithm.GetOrFail("match"),
+		Token:    conformanceToken,
+	})
+	if err != nil {
+		t.Errorf("memory backend is not conformant:\n%s", err)
+	}
+}
@@ -0,0 +1,157 @@
+package memory_test
+
+// Benchmark the memory graph depth first search on a synthetic cluster,
+// with and without pruning subtrees that don't have needed resources.
+// go test -run '^$' -bench . ./plugins/backends/memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/plugins/backends/memory"
+
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+const syntheticName = "synthetic"
+
+// A synthetic cluster has racks of nodes with cores, and every Nth node
+// has gpus
+type synthetic struct {
+	nodes    int
+	racks    int
+	cores    int
+	gpuEvery int
+	gpus     int
+}
+
+var benchmarkCluster = synthetic{nodes: 10000, racks: 100, cores: 16, gpuEvery: 500, gpus: 2}
+
+func BenchmarkSatisfy(b *testing.B) {
+	benchmarkSearch(b, func(cluster *memory.ClusterGraph, matcher algorithm.MatchAlgorithm) error {
+		_, err := cluster.DFSForMatch(gpuJobspec(1, 2), matcher)
+		return err
+	})
+}
+
+func BenchmarkCapacity(b *testing.B) {
+	benchmarkSearch(b, func(cluster *memory.ClusterGraph, matcher algorithm.MatchAlgorithm) error {
+		_, err := cluster.DFSForCapacity(gpuJobspec(2, 2), matcher)
+		return err
+	})
+}
+
+// The gpus are more than a node has, so no node can be part of a match
+func BenchmarkSatisfyTooMany(b *testing.B) {
+	benchmarkSearch(b, func(cluster *memory.ClusterGraph, matcher algorithm.MatchAlgorithm) error {
+		_, err := cluster.DFSForMatch(gpuJobspec(1, 4), matcher)
+		return err
+	})
+}
+
+// benchmarkSearch runs a search with and without pruning
+func benchmarkSearch(b *testing.B, search func(*memory.ClusterGraph, algorithm.MatchAlgorithm) error) {
+	g := memory.NewGraph()
+	nodes, err := benchmarkCluster.generate()
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = g.LoadClusterNodes(syntheticName, nodes, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	matcher := algorithm.GetOrFail("match")
+	cluster := g.Clusters[syntheticName]
+
+	for _, prune := range []bool{false, true} {
+		name := "no-pruning"
+		if prune {
+			name = "pruning"
+		}
+		b.Run(name, func(b *testing.B) {
+			g.SetPruning(prune)
+			for i := 0; i < b.N; i++ {
+				err := search(cluster, matcher)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// gpuJobspec asks for replicas of a node slot, each with some gpus
+func gpuJobspec(replicas, gpus int32) *js.Jobspec {
+	return &js.Jobspec{
+		Version: 1,
+		Resources: map[string]js.Resource{
+			"gpus": {
+				Type:     "node",
+				Replicas: replicas,
+				With:     []js.Resource{{Type: "gpu", Count: gpus}},
+			},
+		},
+		Tasks: []js.Task{{Command: []string{"app"}, Resources: "gpus"}},
+	}
+}
+
+// generate creates JGF for a cluster with racks of nodes
+func (c synthetic) generate() (*jgf.JsonGraph, error) {
+	jgfNodes := map[string]interface{}{}
+	edges := []map[string]string{}
+	counter := 0
+
+	addNode := func(resourceType string, parent string) string {
+		id := fmt.Sprintf("%d", counter)
+		jgfNodes[id] = map[string]interface{}{
+			"label": id,
+			"metadata": map[string]interface{}{
+				"type":     resourceType,
+				"basename": resourceType,
+				"name":     fmt.Sprintf("%s%d", resourceType, counter),
+				"id":       counter,
+				"uniq_id":  counter,
+				"size":     1,
+			},
+		}
+		if parent != "" {
+			edges = append(edges,
+				map[string]string{"source": parent, "target": id, "relation": "contains"},
+				map[string]string{"source": id, "target": parent, "relation": "in"},
+			)
+		}
+		counter += 1
+		return id
+	}
+
+	// The root (cluster) must be the first node
+	root := addNode("cluster", "")
+	perRack := c.nodes / c.racks
+	for r := 0; r < c.racks; r++ {
+		rack := addNode("rack", root)
+		for n := 0; n < perRack; n++ {
+			node := addNode("node", rack)
+			for i := 0; i < c.cores; i++ {
+				addNode("core", node)
+			}
+			if (r*perRack+n+1)%c.gpuEvery == 0 {
+				for i := 0; i < c.gpus; i++ {
+					addNode("gpu", node)
+				}
+			}
+		}
+	}
+	out, err := json.Marshal(map[string]interface{}{
+		"graph": map[string]interface{}{"directed": true, "nodes": jgfNodes, "edges": edges},
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := graph.ReadNodeJsonGraphString(string(out))
+	return &nodes, err
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
@@ -18,10 +19,13 @@ import (
 	rlog "github.com/converged-computing/rainbow/pkg/logger"
 	"github.com/converged-computing/rainbow/pkg/types"
 	"github.com/converged-computing/rainbow/pkg/utils"
+	"github.com/converged-computing/rainbow/plugins/algorithms/shared"
 	"github.com/converged-computing/rainbow/plugins/backends/memory/service"
 )
 
 // A graph holds one or more named clusters
+// Requests are served concurrently, so changes to clusters hold the lock,
+// and searches and reads hold it for reading.
 type Graph struct {
 	Clusters   map[string]*ClusterGraph
 	lock       sync.RWMutex
@@ -29,10 +33,24 @@ type Graph struct {
 
 	// The dominant subsystem for all clusters, if desired to set
 	dominantSubsystem string
+
+	// Cache of satisfy results, and the last generation given to a cluster
+	cache      *SatisfyCache
+	generation uint64
+}
+
+// touch gives a cluster a new generation after it changes
+// We count across the graph so a cluster that is deleted and
+// registered again does not reuse a generation.
+func (g *Graph) touch(cluster *ClusterGraph) {
+	cluster.generation = atomic.AddUint64(&g.generation, 1)
 }
 
 // GetStates for clusters in the graph
 func (g *Graph) GetStates(names []string) (map[string]types.ClusterState, error) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
 	states := map[string]types.ClusterState{}
 	for _, name := range names {
 
@@ -46,8 +64,21 @@ func (g *Graph) GetStates(names []string) (map[string]types.ClusterState, error)
 	return states, nil
 }
 
+// SetPruning enables or disables skipping subtrees during search
+// This is intended for comparing performance, and is enabled by default
+func (g *Graph) SetPruning(prune bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for _, cluster := range g.Clusters {
+		cluster.prune = prune
+	}
+}
+
 // UpdateState updates the state of a known cluster in the graph
 func (g *Graph) UpdateState(name string, state *types.ClusterState) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
 	cluster, ok := g.Clusters[name]
 	if !ok {
 		return fmt.Errorf("cluster %s does not exist", name)
@@ -57,6 +88,7 @@ func (g *Graph) UpdateState(name string, state *types.ClusterState) error {
 		rlog.Debugf("Updating state %s to %v\n", key, value)
 		cluster.State[key] = value
 	}
+	g.touch(cluster)
 	return nil
 }
 
@@ -65,7 +97,11 @@ func NewGraph() *Graph {
 
 	// Set the dominant subsystem to cluster for now
 	clusters := map[string]*ClusterGraph{}
-	g := Graph{dominantSubsystem: types.DefaultDominantSubsystem, Clusters: clusters}
+	g := Graph{
+		dominantSubsystem: types.DefaultDominantSubsystem,
+		Clusters:          clusters,
+		cache:             NewSatisfyCache(defaultCacheSize),
+	}
 
 	// Listen for syscalls to exit
 	g.awaitExit()
@@ -111,6 +147,29 @@ func (g *Graph) RegisterCluster(
 	return &response, err
 }
 
+// RegisterSubsystem loads a json graph of subsystem nodes for a cluster
+func (g *Graph) RegisterSubsystem(
+	name string,
+	payload string,
+	subsystem string,
+) (*service.Response, error) {
+
+	response := service.Response{}
+	nodes, err := graph.ReadNodeJsonGraphString(payload)
+	if err != nil {
+		return nil, errors.New("subsystem nodes are invalid")
+	}
+	err = g.LoadSubsystemNodes(name, &nodes, subsystem)
+	return &response, err
+}
+
+// SetBackupFile sets the file to save the graph to on exit,
+// and loads it if it already exists
+func (g *Graph) SetBackupFile(backupFile string) error {
+	g.backupFile = backupFile
+	return g.LoadBackup()
+}
+
 // LoadClusterNodes loads a new cluster into the graph
 func (g *Graph) LoadClusterNodes(
 	clusterName string,
@@ -119,23 +178,33 @@ func (g *Graph) LoadClusterNodes(
 ) error {
 
 	// Do we already have the graph?
-	_, ok := g.Clusters[clusterName]
-	if ok {
+	if g.HasCluster(clusterName) {
 		return fmt.Errorf("cluster graph %s already exists and cannot be added again", clusterName)
 	}
 
-	// Create a new ClusterGraph
+	// Create a new ClusterGraph. We build it before taking the lock so
+	// searches of other clusters are not blocked, and check again after.
 	clusterG := NewClusterGraph(clusterName, subsystem)
 	err := clusterG.LoadClusterNodes(nodes, subsystem)
 	if err != nil {
 		return err
 	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	_, ok := g.Clusters[clusterName]
+	if ok {
+		return fmt.Errorf("cluster graph %s already exists and cannot be added again", clusterName)
+	}
+	g.touch(clusterG)
 	g.Clusters[clusterName] = clusterG
 	return nil
 }
 
 // DeleteCluster removes a cluster and subsystems entirely
 func (g *Graph) DeleteCluster(clusterName string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
 
 	// Do we already have the graph?
 	_, ok := g.Clusters[clusterName]
@@ -148,6 +217,9 @@ func (g *Graph) DeleteCluster(clusterName string) error {
 
 // DeleteCluster removes a cluster and subsystems entirely
 func (g *Graph) DeleteSubsystem(clusterName, subsystem string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
 	cluster, ok := g.Clusters[clusterName]
 	if !ok {
 		return fmt.Errorf("cluster graph %s does not exist", clusterName)
@@ -157,7 +229,8 @@ func (g *Graph) DeleteSubsystem(clusterName, subsystem string) error {
 	if !ok {
 		return fmt.Errorf("cluster graph %s does not have subsystem %s", clusterName, subsystem)
 	}
-	delete(cluster.subsystem, subsystem)
+	cluster.deleteSubsystem(subsystem)
+	g.touch(cluster)
 	g.Clusters[clusterName] = cluster
 	return nil
 }
@@ -165,12 +238,16 @@ func (g *Graph) DeleteSubsystem(clusterName, subsystem string) error {
 // Satisfy should:
 // 1. Read in and populate the payload into a jobspec
 // 2. Determine by way of a depth first search if we can satisfy
-// 3. Return the names of the cluster
+// 3. Return the names of the cluster, and (if asked) why others do not match
+// Matched clusters also return the vertices chosen for each slot, and
+// the sizes chosen for count ranges.
 func (g *Graph) Satisfies(
 	payload string,
 	matcher algorithm.MatchAlgorithm,
+	explain bool,
+	names []string,
 ) (*service.SatisfyResponse, error) {
-	response := service.SatisfyResponse{}
+	response := service.SatisfyResponse{Sizes: map[string]*service.ResourceSizes{}}
 
 	// Serialize back into Jobspec
 	jobspec := js.Jobspec{}
@@ -182,23 +259,54 @@ func (g *Graph) Satisfies(
 	// Tell the user /logs we are looking for a match
 	rlog.Debugf("\n🍇️ Satisfy request to Graph 🍇️\n")
 	rlog.Debugf(" jobspec: %s\n", payload)
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
 	matches := []string{}
 	notMatches := []string{}
 
+	// Identical requests (jobspec, matcher, and options) share cached results
+	key, err := cacheKey(&jobspec, matcher)
+	if err != nil {
+		return &response, err
+	}
+
+	// Only search the clusters we are asked for (or all of them)
+	// A name we do not know is not searched, and not reported
+	clusters := g.Clusters
+	if len(names) > 0 {
+		clusters = map[string]*ClusterGraph{}
+		for _, clusterName := range names {
+			clusterG, ok := g.Clusters[clusterName]
+			if ok {
+				clusters[clusterName] = clusterG
+			}
+		}
+	}
+
 	// Determine if each cluster can match
-	for clusterName, clusterG := range g.Clusters {
-		isMatch, err := clusterG.DFSForMatch(&jobspec, matcher)
+	for clusterName, clusterG := range clusters {
+		result, err := g.satisfyCluster(clusterG, &jobspec, matcher, key)
 
 		// Return early if we hit an error
 		if err != nil {
 			response.Status = service.SatisfyResponse_RESULT_TYPE_ERROR
 			return &response, err
 		}
-		if isMatch {
+		if result.IsMatch {
 			matches = append(matches, clusterName)
+			response.Placements = append(response.Placements, newPlacement(clusterName, result.Placements))
+			if len(result.Sizes) > 0 {
+				response.Sizes[clusterName] = &service.ResourceSizes{Sizes: result.Sizes}
+			}
 		} else {
 			notMatches = append(notMatches, clusterName)
-			// fmt.Printf("  match: 🎯️ cluster %s does not have sufficient resources and is NOT a match\n", clusterName)
+			for _, explanation := range result.Explanations {
+				rlog.Debugf("  match: 🎯️ cluster %s is NOT a match: %s\n", clusterName, explanation.String())
+			}
+			if explain {
+				response.Mismatches = append(response.Mismatches, newMismatch(clusterName, result.Explanations))
+			}
 		}
 
 	}
@@ -210,15 +318,111 @@ func (g *Graph) Satisfies(
 			fmt.Printf("         🎯️ there are %d clusters that do not match\n", len(notMatches))
 		}
 	}
+	// Matches are scored by the preferences they satisfy, if there are any
+	response.Scores, err = shared.ScoreMatches(&jobspec, matcher, matches, func(required *js.Jobspec, names []string) ([]string, error) {
+		return g.satisfyPreference(required, matcher, names)
+	})
+	if err != nil {
+		response.Status = service.SatisfyResponse_RESULT_TYPE_ERROR
+		return &response, err
+	}
+
 	// Add the matches to the response
 	response.Clusters = matches
-	response.TotalClusters = int32(len(g.Clusters))
+	response.TotalClusters = int32(len(clusters))
 	response.TotalMatches = int32(len(matches))
 	response.TotalMismatches = int32(len(notMatches))
 	response.Status = service.SatisfyResponse_RESULT_TYPE_SUCCESS
 	return &response, nil
 }
 
+// satisfyPreference returns the clusters that match a jobspec with a
+// preference required. Like any request, the results are cached.
+func (g *Graph) satisfyPreference(
+	jobspec *js.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	names []string,
+) ([]string, error) {
+
+	matches := []string{}
+	key, err := cacheKey(jobspec, matcher)
+	if err != nil {
+		return matches, err
+	}
+	for _, clusterName := range names {
+		result, err := g.satisfyCluster(g.Clusters[clusterName], jobspec, matcher, key)
+		if err != nil {
+			return matches, err
+		}
+		if result.IsMatch {
+			matches = append(matches, clusterName)
+		}
+	}
+	return matches, nil
+}
+
+// satisfyCluster returns the match result for one cluster, from the cache
+// if the cluster has not changed since the same request was made
+func (g *Graph) satisfyCluster(
+	clusterG *ClusterGraph,
+	jobspec *js.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	key string,
+) (*MatchResult, error) {
+
+	metrics := &clusterG.DominantSubsystem().Metrics
+	result, ok := g.cache.Get(clusterG.Name, key, clusterG.generation)
+	if ok {
+		metrics.IncCacheHit()
+		rlog.Debugf("  cache: 🎯️ found result for cluster %s (%d hits, %d misses)\n", clusterG.Name, atomic.LoadInt64(&metrics.CacheHits), atomic.LoadInt64(&metrics.CacheMisses))
+		return result, nil
+	}
+	metrics.IncCacheMiss()
+	result, err := clusterG.DFSForMatch(jobspec, matcher)
+	if err != nil {
+		return result, err
+	}
+	g.cache.Add(clusterG.Name, key, clusterG.generation, result)
+	return result, nil
+}
+
+// Capacity determines how many copies of a jobspec each cluster can host
+// If no cluster names are provided, we ask all clusters.
+func (g *Graph) Capacity(
+	payload string,
+	matcher algorithm.MatchAlgorithm,
+	names []string,
+) (*service.CapacityResponse, error) {
+	response := service.CapacityResponse{Capacity: map[string]int32{}}
+
+	jobspec := js.Jobspec{}
+	err := json.Unmarshal([]byte(payload), &jobspec)
+	if err != nil {
+		return &response, err
+	}
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if len(names) == 0 {
+		for clusterName := range g.Clusters {
+			names = append(names, clusterName)
+		}
+	}
+	for _, clusterName := range names {
+		clusterG, ok := g.Clusters[clusterName]
+		if !ok {
+			return &response, fmt.Errorf("cluster %s does not exist", clusterName)
+		}
+		capacity, err := clusterG.DFSForCapacity(&jobspec, matcher)
+		if err != nil {
+			return &response, err
+		}
+		rlog.Debugf("  capacity: cluster %s can fit %d copies\n", clusterName, capacity)
+		response.Capacity[clusterName] = capacity
+	}
+	return &response, nil
+}
+
 // await listens for syscalls and exits when they happen
 func (g *Graph) awaitExit() {
 	var stopper = make(chan os.Signal, 1)
@@ -286,12 +490,18 @@ func (g *Graph) LoadBackup() error {
 	defer fp.Close()
 
 	// Load the subsystem from the filesystem gob
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
 	dec := gob.NewDecoder(fp)
 	items := g.Clusters
 	err = dec.Decode(&items)
 	if err == nil {
-		g.lock.Lock()
-		defer g.lock.Unlock()
+
+		// Each cluster gets a new generation, so nothing cached is used
+		for _, cluster := range items {
+			g.touch(cluster)
+		}
 		g.Clusters = items
 	}
 	return err
@@ -307,11 +517,59 @@ func (g *Graph) LoadSubsystemNodes(
 	nodes *jgf.JsonGraph,
 	subsystem string,
 ) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
 
 	// The graph needs to exist to add a subsystem to
 	clusterG, ok := g.Clusters[clusterName]
 	if !ok {
 		return fmt.Errorf("cluster graph %s to register subsytem does not exist", clusterName)
 	}
-	return clusterG.LoadSubsystemNodes(nodes, subsystem)
+	err := clusterG.LoadSubsystemNodes(nodes, subsystem)
+	if err != nil {
+		return err
+	}
+	g.touch(clusterG)
+	return nil
+}
+
+// HasCluster returns true if the graph has a cluster
+func (g *Graph) HasCluster(clusterName string) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	_, ok := g.Clusters[clusterName]
+	return ok
+}
+
+// newMismatch converts explanations for a cluster to the service type
+func newMismatch(clusterName string, explanations []types.Explanation) *service.Mismatch {
+	mismatch := service.Mismatch{Cluster: clusterName}
+	for _, explanation := range explanations {
+		mismatch.Explanations = append(mismatch.Explanations, &service.Explanation{
+			Reason:   explanation.Reason,
+			Resource: explanation.Resource,
+			Needed:   explanation.Needed,
+			Found:    explanation.Found,
+			Unmet:    explanation.Unmet,
+		})
+	}
+	return &mismatch
+}
+
+// newPlacement converts slot placements for a cluster to the service type
+func newPlacement(clusterName string, slots []types.SlotPlacement) *service.Placement {
+	placement := service.Placement{Cluster: clusterName}
+	for _, slot := range slots {
+		subsystems := map[string]*service.VertexIds{}
+		for subsystem, ids := range slot.Subsystems {
+			subsystems[subsystem] = &service.VertexIds{Ids: ids}
+		}
+		placement.Slots = append(placement.Slots, &service.SlotPlacement{
+			Slot:       slot.Slot,
+			Replica:    slot.Replica,
+			Vertices:   slot.Vertices,
+			Subsystems: subsystems,
+		})
+	}
+	return &placement
 }
@@ -0,0 +1,102 @@
+package memory
+
+// Check that a graph can be changed and searched by concurrent requests,
+// as it is by the graph server. Run with the race detector (make test).
+// go test -race -run TestConcurrentRequests ./plugins/backends/memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/types"
+)
+
+const concurrentClusters = 8
+
+func TestConcurrentRequests(t *testing.T) {
+	examples := filepath.Join("..", "..", "..", "docs", "examples", "scheduler")
+	nodes, err := os.ReadFile(filepath.Join(examples, "cluster-nodes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(examples, "jobspec-io.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := jobspec.JobspecToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher := algorithm.GetOrFail("match")
+
+	// Clusters are registered, changed, and deleted while others search
+	g := NewGraph()
+	names := []string{}
+	for i := 0; i < concurrentClusters; i++ {
+		names = append(names, fmt.Sprintf("cluster-%d", i))
+	}
+	errs := make(chan error, 4*concurrentClusters)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(2)
+		go func(i int, name string) {
+			defer wg.Done()
+			_, err := g.RegisterCluster(name, string(nodes), "")
+			if err != nil {
+				errs <- err
+				return
+			}
+			err = g.UpdateState(name, &types.ClusterState{"index": i})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if i%2 == 0 {
+				errs <- g.DeleteCluster(name)
+			}
+		}(i, name)
+		go func(name string) {
+			defer wg.Done()
+			_, err := g.Satisfies(payload, matcher, true, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			_, err = g.Capacity(payload, matcher, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			g.HasCluster(name)
+			g.GetStates([]string{})
+		}(name)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The clusters that were not deleted remain, with their state
+	states, err := g.GetStates(names[1:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states[names[1]]["index"] != 1 {
+		t.Errorf("state for %s is %v, expected index 1", names[1], states[names[1]])
+	}
+	response, err := g.Satisfies(payload, matcher, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.TotalClusters != concurrentClusters/2 {
+		t.Errorf("satisfy searched %d clusters, expected %d", response.TotalClusters, concurrentClusters/2)
+	}
+}
@@ -3,25 +3,26 @@ package memory
 // The rainbow memory backend - vanilla / prototype
 
 import (
-	"context"
 	"encoding/json"
 	"log"
 
 	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
 	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
 
+	"github.com/converged-computing/rainbow/pkg/certs"
 	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
 	"github.com/converged-computing/rainbow/pkg/graph/backend"
 	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/pkg/utils"
 	"github.com/converged-computing/rainbow/plugins/backends/memory/service"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 // This is the global, in memory graph handle
 var (
 	memoryHost  = ":50051"
 	graphClient *Graph
+	backupFile  string
 )
 
 type MemoryGraph struct{}
@@ -44,20 +45,30 @@ func (m MemoryGraph) Description() string {
 // but since this is directly in the rainbow cluster, we call
 // the functions directly. The "addCluster" here is referring
 // to the dominant subsystem, while a "subsystem" below is
-// considered supplementary to that.
+// considered supplementary to that. If the graph is remote,
+// we forward the request to it instead.
 func (m MemoryGraph) AddCluster(
 	name string,
 	nodes *jgf.JsonGraph,
 	subsystem string,
 ) error {
+	if remoteGraph {
+		return remoteRegister(name, nodes, subsystem, false)
+	}
 	return graphClient.LoadClusterNodes(name, nodes, subsystem)
 }
 
 func (m MemoryGraph) DeleteCluster(name string) error {
+	if remoteGraph {
+		return remoteDelete(name, "")
+	}
 	return graphClient.DeleteCluster(name)
 }
 
 func (m MemoryGraph) DeleteSubsystem(name, subsystem string) error {
+	if remoteGraph {
+		return remoteDelete(name, subsystem)
+	}
 	return graphClient.DeleteSubsystem(name, subsystem)
 }
 
@@ -72,11 +83,17 @@ func (m MemoryGraph) UpdateState(
 	if err != nil {
 		return err
 	}
+	if remoteGraph {
+		return remoteUpdateState(name, payload)
+	}
 	return graphClient.UpdateState(name, &state)
 }
 
 // GetStates for a list of clusters
 func (m MemoryGraph) GetStates(names []string) (map[string]types.ClusterState, error) {
+	if remoteGraph {
+		return remoteGetStates(names)
+	}
 	return graphClient.GetStates(names)
 }
 
@@ -87,62 +104,111 @@ func (m MemoryGraph) AddSubsystem(
 	nodes *jgf.JsonGraph,
 	subsystem string,
 ) error {
+	if remoteGraph {
+		return remoteRegister(name, nodes, subsystem, true)
+	}
 	return graphClient.LoadSubsystemNodes(name, nodes, subsystem)
 }
 
-func (m MemoryGraph) RegisterService(s *grpc.Server) error {
+// RegisterService serves the graph from rainbow. Requests must carry
+// the graph token, or cluster tokens that are checked with the validator.
+func (m MemoryGraph) RegisterService(s *grpc.Server, validate backend.ClusterValidator) error {
+
+	// A remote graph is served on its own (cmd/graph)
+	if remoteGraph && shards != nil {
+		log.Printf("🧠️ Using %d remote memory graph shards: %s\n", len(shards.hosts), shards.hosts)
+		return nil
+	}
+	if remoteGraph {
+		log.Printf("🧠️ Using remote memory graph database at %s\n", memoryHost)
+		return nil
+	}
 
 	// This is akin to calling init
 	// The service is in the same module as here, so is available to the grpc functions
+	if graphToken == "" && validate == nil {
+		return errNoCredentials
+	}
 	log.Printf("🧠️ Registering memory graph database...\n")
 
 	graphClient = NewGraph()
-
-	service.RegisterMemoryGraphServer(s, MemoryServer{})
+	if backupFile != "" {
+		err := graphClient.SetBackupFile(backupFile)
+		if err != nil {
+			return err
+		}
+	}
+	service.RegisterMemoryGraphServer(s, MemoryServer{graph: graphClient, token: graphToken, validate: validate})
 	return nil
 }
 
 // Satisfies - determine what clusters satisfy a jobspec request
 // Since this is called from the client function, it's technically
-// running from the client (not from the server)
+// running from the client (not from the server). The cluster tokens
+// are sent with the request, and only those clusters are searched.
 func (g MemoryGraph) Satisfies(
 	jobspec *js.Jobspec,
 	matcher algorithm.MatchAlgorithm,
-) ([]string, error) {
+	explain bool,
+	clusters map[string]string,
+) (*types.SatisfyResult, error) {
 
-	matches := []string{}
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	conn, err := grpc.Dial(memoryHost, opts...)
+	// Prepare a satisfy request, the jobspec needs to be serialized to string
+	out, err := json.Marshal(jobspec)
 	if err != nil {
-		return matches, err
+		return types.NewSatisfyResult(), err
+	}
+	// Make the satisfy request, ensuring we provide the graph algorithm
+	// and its options, since the graph makes its own matcher
+	request := service.SatisfyRequest{
+		Payload:      string(out),
+		Matcher:      matcher.Name(),
+		MatchOptions: algorithm.Options(matcher),
+		Explain:      explain,
+		Clusters:     utils.Keys(clusters),
 	}
-	defer conn.Close()
-	client := service.NewMemoryGraphClient(conn)
+	return remoteSatisfy(&request, clusters)
+}
 
-	// Prepare a satisfy request, the jobspec needs to be serialized to string
+// Capacity determines how many copies of a jobspec each cluster can host
+// This is called by the rainbow server, so when the graph is served by
+// rainbow we ask it directly (and do not need credentials).
+func (g MemoryGraph) Capacity(
+	jobspec *js.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	names []string,
+) (map[string]int32, error) {
+
+	capacity := map[string]int32{}
 	out, err := json.Marshal(jobspec)
 	if err != nil {
-		return matches, err
+		return capacity, err
+	}
+
+	var response *service.CapacityResponse
+	if !remoteGraph && graphClient != nil {
+		response, err = graphClient.Capacity(string(out), matcher, names)
+	} else {
+		response, err = remoteCapacity(string(out), matcher, names)
 	}
-	// Make the satisfy request, ensuring we provide the graph algorithm
-	request := service.SatisfyRequest{Payload: string(out), Matcher: matcher.Name()}
-	ctx := context.Background()
-	response, err := client.Satisfy(ctx, &request)
 	if err != nil {
-		return matches, err
+		return capacity, err
+	}
+	for clusterName, count := range response.Capacity {
+		capacity[clusterName] = count
 	}
-	return response.Clusters, nil
+	return capacity, nil
 }
 
 // Init provides extra initialization functionality, if needed
-// The in memory database can take a backup file if desired
+// The in memory database can take a backup file if desired,
+// or point to a remote graph (cmd/graph) with a token and certificates
 func (g MemoryGraph) Init(
 	options map[string]string,
 ) error {
-	backupFile, ok := options["backupFile"]
+	file, ok := options["backupFile"]
 	if ok {
-		graphClient.backupFile = backupFile
+		backupFile = file
 	}
 
 	// Warning: this assumes one client running with one graph host
@@ -150,6 +216,37 @@ func (g MemoryGraph) Init(
 	if ok {
 		memoryHost = host
 	}
+
+	// More than one host shards clusters across remote graphs
+	hosts, ok := options["hosts"]
+	if ok {
+		list := parseHosts(hosts)
+		if len(list) > 1 {
+			shards = newHashRing(list, defaultRingReplicas)
+		}
+		if len(list) > 0 {
+			memoryHost = list[0]
+			remoteGraph = true
+		}
+	}
+	remote, ok := options["remote"]
+	if ok && remote == "true" {
+		remoteGraph = true
+	}
+	token, ok := options["token"]
+	if ok {
+		graphToken = token
+	}
+
+	// Certificates are optional, and only used to reach the graph
+	caCert, certFile, keyFile := options["caCert"], options["cert"], options["key"]
+	if caCert != "" || certFile != "" || keyFile != "" {
+		cert, err := certs.NewClientCertificate(caCert, certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		graphCert = cert
+	}
 	return nil
 }
 
@@ -23,7 +23,8 @@ func (m *Metrics) NewResource(levelName string) {
 	m.ResourceCounts[levelName] = 0
 }
 
-// Show prints a summary of resources for an entire subsystem
+// Show prints a summary of resources for an entire subsystem,
+// and how many satisfy results were found in the cache
 func (m *Metrics) Show() error {
 	fmt.Printf("Metrics for subsystem %s", m.Name)
 	out, err := json.MarshalIndent(m.ResourceCounts, "", " ")
@@ -31,6 +32,7 @@ func (m *Metrics) Show() error {
 		return err
 	}
 	fmt.Println(string(out))
+	fmt.Printf("Satisfy cache for subsystem %s: %d hits, %d misses\n", m.Name, atomic.LoadInt64(&m.CacheHits), atomic.LoadInt64(&m.CacheMisses))
 	return nil
 }
 
@@ -42,6 +44,14 @@ func (m *Metrics) IncReadCount() *Metrics {
 	atomic.AddInt64(&m.Reads, 1)
 	return m
 }
+func (m *Metrics) IncCacheHit() *Metrics {
+	atomic.AddInt64(&m.CacheHits, 1)
+	return m
+}
+func (m *Metrics) IncCacheMiss() *Metrics {
+	atomic.AddInt64(&m.CacheMisses, 1)
+	return m
+}
 
 // Debugging function to print stats
 // example usage:
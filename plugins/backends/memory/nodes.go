@@ -3,6 +3,7 @@ package memory
 import (
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/converged-computing/rainbow/pkg/graph"
 	"github.com/converged-computing/rainbow/pkg/types"
@@ -41,9 +42,17 @@ func (g *ClusterGraph) addNodes(
 	// Create an empty resource counter for the subsystem
 	ss.Metrics.NewResource(subsystem)
 
+	// Add nodes in a consistent order so vertex identifiers are reproducible
+	nids := make([]string, 0, len(nodes.Graph.Nodes))
+	for nid := range nodes.Graph.Nodes {
+		nids = append(nids, nid)
+	}
+	sort.Strings(nids)
+
 	// Now loop through the nodes and add them, keeping a temporary lookup
 	//	lookup[subsystem] = root
-	for nid, node := range nodes.Graph.Nodes {
+	for _, nid := range nids {
+		node := nodes.Graph.Nodes[nid]
 
 		// Currently we are saving the type, size, and unit
 		resource := types.NewResource(node)
@@ -57,6 +66,7 @@ func (g *ClusterGraph) addNodes(
 		// We aren't interested in other metadata here so we don't add it
 		id := ss.AddNode(
 			lookupName,
+			nid,
 			resource.Type,
 			resource.Size,
 			resource.Unit,
@@ -0,0 +1,299 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+	"github.com/converged-computing/rainbow/pkg/certs"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/plugins/backends/memory/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// How to reach the memory graph service. When remote is true, the graph
+// is served separately (cmd/graph) and rainbow does not serve it.
+var (
+	remoteGraph bool
+	graphToken  string
+	graphCert   = &certs.Certificate{}
+)
+
+// dialGraph connects to a memory graph service
+// The caller is responsible for closing the connection
+func dialGraph(host string) (*grpc.ClientConn, service.MemoryGraphClient, error) {
+	var opts []grpc.DialOption
+	if graphCert.IsEmpty() {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(graphCert.GetClientCredentials()))
+	}
+	if graphToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{
+			token:  graphToken,
+			secure: !graphCert.IsEmpty(),
+		}))
+	}
+	conn, err := grpc.Dial(host, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, service.NewMemoryGraphClient(conn), nil
+}
+
+// NewGraphServer creates a grpc server for a standalone memory graph
+// The certificate (if not empty) enables TLS, and the token is required
+// on every request. A standalone graph does not know rainbow's clusters,
+// so cluster tokens are not accepted, and without a token the server
+// must be asked to be insecure (accepting any request). Each server
+// has its own graph, so several can run in one process (e.g., shards).
+func NewGraphServer(cert *certs.Certificate, token, backupFile string, insecure bool) (*grpc.Server, error) {
+	if token == "" && !insecure {
+		return nil, errNoCredentials
+	}
+	var opts []grpc.ServerOption
+	if !cert.IsEmpty() {
+		opts = append(opts, grpc.Creds(cert.GetServerCredentials()))
+	}
+	server := grpc.NewServer(opts...)
+
+	graph := NewGraph()
+	if backupFile != "" {
+		err := graph.SetBackupFile(backupFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load graph backup %s: %s", backupFile, err)
+		}
+	}
+	service.RegisterMemoryGraphServer(server, MemoryServer{graph: graph, token: token, insecure: token == ""})
+	return server, nil
+}
+
+// The functions below forward changes to a remote graph. When the graph is
+// served by rainbow, the same changes are made with direct calls. A change
+// for a cluster goes to the graph (shard) that owns it.
+
+// remoteRegister sends nodes for a cluster, or for a subsystem of it
+func remoteRegister(name string, nodes *jgf.JsonGraph, subsystem string, isSubsystem bool) error {
+	conn, client, err := dialGraph(graphHost(name))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	out, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+	request := service.RegisterRequest{Name: name, Payload: string(out), Subsystem: subsystem}
+	if isSubsystem {
+		_, err = client.RegisterSubsystem(context.Background(), &request)
+	} else {
+		_, err = client.Register(context.Background(), &request)
+	}
+	return err
+}
+
+// remoteDelete deletes a cluster, or a subsystem if one is named
+func remoteDelete(name, subsystem string) error {
+	conn, client, err := dialGraph(graphHost(name))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request := service.DeleteRequest{Name: name, Subsystem: subsystem}
+	if subsystem != "" {
+		_, err = client.DeleteSubsystem(context.Background(), &request)
+	} else {
+		_, err = client.DeleteCluster(context.Background(), &request)
+	}
+	return err
+}
+
+// remoteUpdateState sends a json state payload for a cluster
+func remoteUpdateState(name, payload string) error {
+	conn, client, err := dialGraph(graphHost(name))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = client.UpdateState(context.Background(), &service.StateRequest{Name: name, Payload: payload})
+	return err
+}
+
+// The functions below ask every graph that owns one of the clusters in
+// parallel, and merge the results.
+
+// fanOut calls a function for each host and its clusters in parallel
+// The first error is returned
+func fanOut(groups map[string][]string, call func(host string, names []string) error) error {
+	var wg sync.WaitGroup
+	errors := make(chan error, len(groups))
+	for host, names := range groups {
+		wg.Add(1)
+		go func(host string, names []string) {
+			defer wg.Done()
+			err := call(host, names)
+			if err != nil {
+				errors <- fmt.Errorf("graph %s: %s", host, err)
+			}
+		}(host, names)
+	}
+	wg.Wait()
+	close(errors)
+	return <-errors
+}
+
+// remoteGetStates gets states for clusters
+func remoteGetStates(names []string) (map[string]types.ClusterState, error) {
+	states := map[string]types.ClusterState{}
+	var lock sync.Mutex
+
+	err := fanOut(hostGroups(names), func(host string, names []string) error {
+		conn, client, err := dialGraph(host)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		response, err := client.GetStates(context.Background(), &service.StatesRequest{Clusters: names})
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for name, payload := range response.States {
+			state := types.ClusterState{}
+			err := json.Unmarshal([]byte(payload), &state)
+			if err != nil {
+				return err
+			}
+			states[name] = state
+		}
+		return nil
+	})
+	return states, err
+}
+
+// remoteCapacity asks the graph service how many copies each cluster can host
+func remoteCapacity(payload string, matcher algorithm.MatchAlgorithm, names []string) (*service.CapacityResponse, error) {
+	capacity := service.CapacityResponse{Capacity: map[string]int32{}}
+	var lock sync.Mutex
+
+	err := fanOut(hostGroups(names), func(host string, names []string) error {
+		conn, client, err := dialGraph(host)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		request := service.CapacityRequest{
+			Payload:      payload,
+			Matcher:      matcher.Name(),
+			MatchOptions: algorithm.Options(matcher),
+			Clusters:     names,
+		}
+		response, err := client.Capacity(context.Background(), &request)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for name, count := range response.Capacity {
+			capacity.Capacity[name] = count
+		}
+		return nil
+	})
+	return &capacity, err
+}
+
+// remoteSatisfy asks the graph service which clusters satisfy a request
+// Each graph only gets the tokens for the clusters it owns.
+func remoteSatisfy(request *service.SatisfyRequest, clusters map[string]string) (*types.SatisfyResult, error) {
+	matches := types.NewSatisfyResult()
+	var lock sync.Mutex
+
+	err := fanOut(hostGroups(request.Clusters), func(host string, names []string) error {
+		conn, client, err := dialGraph(host)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		tokens := map[string]string{}
+		for _, name := range names {
+			tokens[name] = clusters[name]
+		}
+		shardRequest := service.SatisfyRequest{
+			Payload:      request.Payload,
+			Matcher:      request.Matcher,
+			MatchOptions: request.MatchOptions,
+			Explain:      request.Explain,
+			Clusters:     names,
+		}
+		ctx := withClusterTokens(context.Background(), tokens)
+		response, err := client.Satisfy(ctx, &shardRequest)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		addSatisfyResponse(matches, response)
+		return nil
+	})
+	sort.Strings(matches.Clusters)
+	return matches, err
+}
+
+// addSatisfyResponse adds clusters, explanations, placements, scores, sizes, and totals
+// from a graph service response to a result
+func addSatisfyResponse(matches *types.SatisfyResult, response *service.SatisfyResponse) {
+	matches.Clusters = append(matches.Clusters, response.Clusters...)
+	matches.TotalClusters += response.TotalClusters
+	matches.TotalMatches += response.TotalMatches
+	matches.TotalMismatches += response.TotalMismatches
+
+	// Explanations are only returned if we asked for them
+	for _, mismatch := range response.Mismatches {
+		explanations := []types.Explanation{}
+		for _, explanation := range mismatch.Explanations {
+			explanations = append(explanations, types.Explanation{
+				Reason:   explanation.Reason,
+				Resource: explanation.Resource,
+				Needed:   explanation.Needed,
+				Found:    explanation.Found,
+				Unmet:    explanation.Unmet,
+			})
+		}
+		matches.Mismatches[mismatch.Cluster] = explanations
+	}
+
+	// Placements are returned for clusters that match
+	for _, placement := range response.Placements {
+		slots := []types.SlotPlacement{}
+		for _, slot := range placement.Slots {
+			subsystems := map[string][]string{}
+			for subsystem, ids := range slot.Subsystems {
+				subsystems[subsystem] = ids.Ids
+			}
+			slots = append(slots, types.SlotPlacement{
+				Slot:       slot.Slot,
+				Replica:    slot.Replica,
+				Vertices:   slot.Vertices,
+				Subsystems: subsystems,
+			})
+		}
+		matches.Placements[placement.Cluster] = slots
+	}
+	for cluster, score := range response.Scores {
+		matches.Scores[cluster] = score
+	}
+	for cluster, sizes := range response.Sizes {
+		matches.Sizes[cluster] = sizes.Sizes
+	}
+}
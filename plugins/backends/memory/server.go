@@ -2,19 +2,46 @@ package memory
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/converged-computing/rainbow/pkg/config"
 	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend"
+	"github.com/converged-computing/rainbow/pkg/types"
 	"github.com/converged-computing/rainbow/plugins/backends/memory/service"
 )
 
+// MemoryServer serves the memory graph. Changes to the graph require the
+// graph token, and reads are limited to the clusters a caller can access.
 type MemoryServer struct {
 	service.UnimplementedMemoryGraphServer
+	graph    *Graph
+	token    string
+	validate backend.ClusterValidator
+
+	// An insecure server does not check credentials
+	insecure bool
+}
+
+// authorizeAll ensures the caller has access to the entire graph
+func (s MemoryServer) authorizeAll(c context.Context) error {
+	allowed, err := s.authorize(c)
+	if err != nil {
+		return err
+	}
+	if !allowed.all {
+		return errDenied
+	}
+	return nil
 }
 
 // Register takes a cluster node payload and adds to the in memory graph
-func (MemoryServer) Register(c context.Context, req *service.RegisterRequest) (*service.Response, error) {
-	response, err := graphClient.RegisterCluster(req.Name, req.Payload, req.Subsystem)
+func (s MemoryServer) Register(c context.Context, req *service.RegisterRequest) (*service.Response, error) {
+	err := s.authorizeAll(c)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.graph.RegisterCluster(req.Name, req.Payload, req.Subsystem)
 	if err != nil {
 		return nil, err
 	}
@@ -22,18 +49,135 @@ func (MemoryServer) Register(c context.Context, req *service.RegisterRequest) (*
 }
 
 // Satisfy determines if the graph can satisfy a request
-func (MemoryServer) Satisfy(c context.Context, req *service.SatisfyRequest) (*service.SatisfyResponse, error) {
+// Only clusters the caller can access (and asked for) are searched
+func (s MemoryServer) Satisfy(c context.Context, req *service.SatisfyRequest) (*service.SatisfyResponse, error) {
+	allowed, err := s.authorize(c)
+	if err != nil {
+		return nil, err
+	}
+	if req.Matcher == "" {
+		req.Matcher = config.DefaultMatchAlgorithm
+	}
+	// Instantiate the matcher with the options of the caller
+	matcher, err := algorithm.New(req.Matcher, req.MatchOptions)
+	if err != nil {
+		return nil, err
+	}
+	names := allowed.candidates(req.Clusters)
+	if !allowed.all && len(names) == 0 {
+		return &service.SatisfyResponse{Status: service.SatisfyResponse_RESULT_TYPE_SUCCESS}, nil
+	}
+	response, err := s.graph.Satisfies(req.Payload, matcher, req.Explain, names)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Capacity determines how many copies of a request each cluster can host
+func (s MemoryServer) Capacity(c context.Context, req *service.CapacityRequest) (*service.CapacityResponse, error) {
+	allowed, err := s.authorize(c)
+	if err != nil {
+		return nil, err
+	}
 	if req.Matcher == "" {
 		req.Matcher = config.DefaultMatchAlgorithm
 	}
-	// Instantiate the matcher
-	matcher, err := algorithm.Get(req.Matcher)
+	matcher, err := algorithm.New(req.Matcher, req.MatchOptions)
 	if err != nil {
 		return nil, err
 	}
-	response, err := graphClient.Satisfies(req.Payload, matcher)
+	// A cluster the caller can access might not be in the graph yet
+	names := []string{}
+	for _, cluster := range allowed.candidates(req.Clusters) {
+		if allowed.all || s.graph.HasCluster(cluster) {
+			names = append(names, cluster)
+		}
+	}
+	if !allowed.all && len(names) == 0 {
+		return &service.CapacityResponse{Capacity: map[string]int32{}}, nil
+	}
+	response, err := s.graph.Capacity(req.Payload, matcher, names)
 	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
+
+// RegisterSubsystem adds a subsystem to a cluster already in the graph
+func (s MemoryServer) RegisterSubsystem(c context.Context, req *service.RegisterRequest) (*service.Response, error) {
+	err := s.authorizeAll(c)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.graph.RegisterSubsystem(req.Name, req.Payload, req.Subsystem)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// DeleteCluster removes a cluster and its subsystems from the graph
+func (s MemoryServer) DeleteCluster(c context.Context, req *service.DeleteRequest) (*service.Response, error) {
+	err := s.authorizeAll(c)
+	if err != nil {
+		return nil, err
+	}
+	err = s.graph.DeleteCluster(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &service.Response{Status: service.Response_RESULT_TYPE_SUCCESS}, nil
+}
+
+// DeleteSubsystem removes a subsystem from a cluster in the graph
+func (s MemoryServer) DeleteSubsystem(c context.Context, req *service.DeleteRequest) (*service.Response, error) {
+	err := s.authorizeAll(c)
+	if err != nil {
+		return nil, err
+	}
+	err = s.graph.DeleteSubsystem(req.Name, req.Subsystem)
+	if err != nil {
+		return nil, err
+	}
+	return &service.Response{Status: service.Response_RESULT_TYPE_SUCCESS}, nil
+}
+
+// UpdateState updates the state of a cluster from a json payload
+func (s MemoryServer) UpdateState(c context.Context, req *service.StateRequest) (*service.Response, error) {
+	err := s.authorizeAll(c)
+	if err != nil {
+		return nil, err
+	}
+	state := types.ClusterState{}
+	err = json.Unmarshal([]byte(req.Payload), &state)
+	if err != nil {
+		return nil, err
+	}
+	err = s.graph.UpdateState(req.Name, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &service.Response{Status: service.Response_RESULT_TYPE_SUCCESS}, nil
+}
+
+// GetStates returns the json state for each requested cluster
+func (s MemoryServer) GetStates(c context.Context, req *service.StatesRequest) (*service.StatesResponse, error) {
+	allowed, err := s.authorize(c)
+	if err != nil {
+		return nil, err
+	}
+	states, err := s.graph.GetStates(allowed.filter(req.Clusters))
+	if err != nil {
+		return nil, err
+	}
+	response := service.StatesResponse{States: map[string]string{}}
+	for name, state := range states {
+		out, err := json.Marshal(state)
+		if err != nil {
+			return nil, err
+		}
+		response.States[name] = string(out)
+	}
+	return &response, nil
+}
@@ -23,7 +23,13 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type MemoryGraphClient interface {
 	Satisfy(ctx context.Context, in *SatisfyRequest, opts ...grpc.CallOption) (*SatisfyResponse, error)
+	Capacity(ctx context.Context, in *CapacityRequest, opts ...grpc.CallOption) (*CapacityResponse, error)
 	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Response, error)
+	RegisterSubsystem(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Response, error)
+	DeleteCluster(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error)
+	DeleteSubsystem(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error)
+	UpdateState(ctx context.Context, in *StateRequest, opts ...grpc.CallOption) (*Response, error)
+	GetStates(ctx context.Context, in *StatesRequest, opts ...grpc.CallOption) (*StatesResponse, error)
 }
 
 type memoryGraphClient struct {
@@ -43,6 +49,15 @@ func (c *memoryGraphClient) Satisfy(ctx context.Context, in *SatisfyRequest, opt
 	return out, nil
 }
 
+func (c *memoryGraphClient) Capacity(ctx context.Context, in *CapacityRequest, opts ...grpc.CallOption) (*CapacityResponse, error) {
+	out := new(CapacityResponse)
+	err := c.cc.Invoke(ctx, "/service.MemoryGraph/Capacity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *memoryGraphClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := c.cc.Invoke(ctx, "/service.MemoryGraph/Register", in, out, opts...)
@@ -52,12 +67,63 @@ func (c *memoryGraphClient) Register(ctx context.Context, in *RegisterRequest, o
 	return out, nil
 }
 
+func (c *memoryGraphClient) RegisterSubsystem(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/service.MemoryGraph/RegisterSubsystem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memoryGraphClient) DeleteCluster(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/service.MemoryGraph/DeleteCluster", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memoryGraphClient) DeleteSubsystem(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/service.MemoryGraph/DeleteSubsystem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memoryGraphClient) UpdateState(ctx context.Context, in *StateRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/service.MemoryGraph/UpdateState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memoryGraphClient) GetStates(ctx context.Context, in *StatesRequest, opts ...grpc.CallOption) (*StatesResponse, error) {
+	out := new(StatesResponse)
+	err := c.cc.Invoke(ctx, "/service.MemoryGraph/GetStates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MemoryGraphServer is the server API for MemoryGraph service.
 // All implementations must embed UnimplementedMemoryGraphServer
 // for forward compatibility
 type MemoryGraphServer interface {
 	Satisfy(context.Context, *SatisfyRequest) (*SatisfyResponse, error)
+	Capacity(context.Context, *CapacityRequest) (*CapacityResponse, error)
 	Register(context.Context, *RegisterRequest) (*Response, error)
+	RegisterSubsystem(context.Context, *RegisterRequest) (*Response, error)
+	DeleteCluster(context.Context, *DeleteRequest) (*Response, error)
+	DeleteSubsystem(context.Context, *DeleteRequest) (*Response, error)
+	UpdateState(context.Context, *StateRequest) (*Response, error)
+	GetStates(context.Context, *StatesRequest) (*StatesResponse, error)
 	mustEmbedUnimplementedMemoryGraphServer()
 }
 
@@ -68,9 +134,27 @@ type UnimplementedMemoryGraphServer struct {
 func (UnimplementedMemoryGraphServer) Satisfy(context.Context, *SatisfyRequest) (*SatisfyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Satisfy not implemented")
 }
+func (UnimplementedMemoryGraphServer) Capacity(context.Context, *CapacityRequest) (*CapacityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Capacity not implemented")
+}
 func (UnimplementedMemoryGraphServer) Register(context.Context, *RegisterRequest) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
 }
+func (UnimplementedMemoryGraphServer) RegisterSubsystem(context.Context, *RegisterRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterSubsystem not implemented")
+}
+func (UnimplementedMemoryGraphServer) DeleteCluster(context.Context, *DeleteRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCluster not implemented")
+}
+func (UnimplementedMemoryGraphServer) DeleteSubsystem(context.Context, *DeleteRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSubsystem not implemented")
+}
+func (UnimplementedMemoryGraphServer) UpdateState(context.Context, *StateRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateState not implemented")
+}
+func (UnimplementedMemoryGraphServer) GetStates(context.Context, *StatesRequest) (*StatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStates not implemented")
+}
 func (UnimplementedMemoryGraphServer) mustEmbedUnimplementedMemoryGraphServer() {}
 
 // UnsafeMemoryGraphServer may be embedded to opt out of forward compatibility for this service.
@@ -102,6 +186,24 @@ func _MemoryGraph_Satisfy_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MemoryGraph_Capacity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CapacityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemoryGraphServer).Capacity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.MemoryGraph/Capacity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemoryGraphServer).Capacity(ctx, req.(*CapacityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _MemoryGraph_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RegisterRequest)
 	if err := dec(in); err != nil {
@@ -120,6 +222,96 @@ func _MemoryGraph_Register_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MemoryGraph_RegisterSubsystem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemoryGraphServer).RegisterSubsystem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.MemoryGraph/RegisterSubsystem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemoryGraphServer).RegisterSubsystem(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MemoryGraph_DeleteCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemoryGraphServer).DeleteCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.MemoryGraph/DeleteCluster",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemoryGraphServer).DeleteCluster(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MemoryGraph_DeleteSubsystem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemoryGraphServer).DeleteSubsystem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.MemoryGraph/DeleteSubsystem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemoryGraphServer).DeleteSubsystem(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MemoryGraph_UpdateState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemoryGraphServer).UpdateState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.MemoryGraph/UpdateState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemoryGraphServer).UpdateState(ctx, req.(*StateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MemoryGraph_GetStates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemoryGraphServer).GetStates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.MemoryGraph/GetStates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemoryGraphServer).GetStates(ctx, req.(*StatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MemoryGraph_ServiceDesc is the grpc.ServiceDesc for MemoryGraph service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -131,10 +323,34 @@ var MemoryGraph_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Satisfy",
 			Handler:    _MemoryGraph_Satisfy_Handler,
 		},
+		{
+			MethodName: "Capacity",
+			Handler:    _MemoryGraph_Capacity_Handler,
+		},
 		{
 			MethodName: "Register",
 			Handler:    _MemoryGraph_Register_Handler,
 		},
+		{
+			MethodName: "RegisterSubsystem",
+			Handler:    _MemoryGraph_RegisterSubsystem_Handler,
+		},
+		{
+			MethodName: "DeleteCluster",
+			Handler:    _MemoryGraph_DeleteCluster_Handler,
+		},
+		{
+			MethodName: "DeleteSubsystem",
+			Handler:    _MemoryGraph_DeleteSubsystem_Handler,
+		},
+		{
+			MethodName: "UpdateState",
+			Handler:    _MemoryGraph_UpdateState_Handler,
+		},
+		{
+			MethodName: "GetStates",
+			Handler:    _MemoryGraph_GetStates_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "memory.proto",
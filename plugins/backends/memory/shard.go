@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Each graph server gets this many points on the ring, so clusters are
+// spread evenly and only a fraction move when a server is added
+var defaultRingReplicas = 100
+
+// shards assigns clusters to graph servers, and is only set when
+// more than one graph host is provided
+var shards *hashRing
+
+// A hashRing assigns clusters to graph servers by consistent hashing
+type hashRing struct {
+	hosts  []string
+	points []uint32
+	owners map[uint32]string
+}
+
+// newHashRing creates a ring with a number of points for each host
+func newHashRing(hosts []string, replicas int) *hashRing {
+	ring := hashRing{hosts: hosts, owners: map[uint32]string{}}
+	for _, host := range hosts {
+		for i := 0; i < replicas; i++ {
+			point := ringHash(fmt.Sprintf("%s-%d", host, i))
+			ring.points = append(ring.points, point)
+			ring.owners[point] = host
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return &ring
+}
+
+// owner returns the host for a cluster, the first point after its hash
+func (r *hashRing) owner(cluster string) string {
+	hash := ringHash(cluster)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// ringHash places a name on the ring. Names of clusters (and hosts) often
+// differ by a character or two, so we need a hash that spreads them out,
+// and a checksum (e.g., crc32) does not.
+func ringHash(name string) uint32 {
+	sum := sha256.Sum256([]byte(name))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// parseHosts splits a comma separated list of graph hosts
+func parseHosts(value string) []string {
+	hosts := []string{}
+	for _, host := range strings.Split(value, ",") {
+		host = strings.TrimSpace(host)
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// graphHost returns the graph server that owns a cluster
+func graphHost(cluster string) string {
+	if shards == nil {
+		return memoryHost
+	}
+	return shards.owner(cluster)
+}
+
+// hostGroups splits cluster names by the graph server that owns them
+// No names asks every graph server for all of its clusters
+func hostGroups(names []string) map[string][]string {
+	groups := map[string][]string{}
+	if shards == nil {
+		groups[memoryHost] = names
+		return groups
+	}
+	if len(names) == 0 {
+		for _, host := range shards.hosts {
+			groups[host] = nil
+		}
+		return groups
+	}
+	for _, name := range names {
+		host := shards.owner(name)
+		groups[host] = append(groups[host], name)
+	}
+	return groups
+}
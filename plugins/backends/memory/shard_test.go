@@ -0,0 +1,263 @@
+package memory
+
+// Check a memory graph sharded across graph servers in this process.
+// Each server has its own graph, and clusters are assigned to them by
+// consistent hashing.
+// go test -run TestShards ./plugins/backends/memory
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/certs"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/plugins/backends/memory/service"
+
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+const (
+	shardCount   = 3
+	shardMinimum = 8
+	shardToken   = "shard-token"
+	shardCluster = "cluster-%d"
+	examplesDir  = "../../../docs/examples"
+)
+
+func TestShards(t *testing.T) {
+
+	// Start graph servers on ports we are given
+	hosts := []string{}
+	for i := 0; i < shardCount; i++ {
+		server, err := NewGraphServer(&certs.Certificate{}, shardToken, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go server.Serve(listener)
+		t.Cleanup(server.Stop)
+		hosts = append(hosts, listener.Addr().String())
+	}
+
+	// The graph is configured with package variables, so we restore them
+	host := memoryHost
+	t.Cleanup(func() {
+		shards = nil
+		remoteGraph = false
+		graphToken = ""
+		memoryHost = host
+	})
+	m := MemoryGraph{}
+	err := m.Init(map[string]string{"hosts": strings.Join(hosts, ","), "token": shardToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examplesDir, "match-algorithms", "self", "cluster-nodes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ports are chosen for us, so we register clusters until every graph
+	// owns at least one
+	names := []string{}
+	for i := 0; i < 100 && (len(names) < shardMinimum || len(hostGroups(names)) < shardCount); i++ {
+		name := fmt.Sprintf(shardCluster, i)
+		names = append(names, name)
+		err := m.AddCluster(name, &nodes, "")
+		if err != nil {
+			t.Fatalf("register %s: %s", name, err)
+		}
+		err = m.UpdateState(name, fmt.Sprintf(`{"index": %d}`, i))
+		if err != nil {
+			t.Fatalf("update state %s: %s", name, err)
+		}
+	}
+
+	// Registering again goes to the same graph, which already has it
+	err = m.AddCluster(names[0], &nodes, "")
+	if err == nil {
+		t.Errorf("registering %s again should be an error", names[0])
+	}
+
+	matcher := algorithm.GetOrFail("match")
+	matches := loadJobspec(t, "jobspec-two-groups.yaml")
+	payload, err := matches.JobspecToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("clusters are on the graph that owns them", func(t *testing.T) {
+		groups := hostGroups(names)
+		if len(groups) != shardCount {
+			t.Fatalf("clusters are owned by %d graphs, expected %d: %v", len(groups), shardCount, groups)
+		}
+		for _, host := range hosts {
+			owned := groups[host]
+			sort.Strings(owned)
+			found := shardClusters(t, host, payload)
+			if !reflect.DeepEqual(found, owned) {
+				t.Errorf("graph %s has %v, expected %v", host, found, owned)
+			}
+		}
+	})
+
+	t.Run("states are merged from every graph", func(t *testing.T) {
+		states, err := m.GetStates(names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(states) != len(names) {
+			t.Fatalf("states are %v, expected %d", states, len(names))
+		}
+		for i, name := range names {
+			if states[name]["index"] != float64(i) {
+				t.Errorf("state for %s is %v, expected index %d", name, states[name], i)
+			}
+		}
+	})
+
+	t.Run("satisfy fans out to every graph", func(t *testing.T) {
+		result, err := m.Satisfies(matches, matcher, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result.Clusters, names) {
+			t.Errorf("clusters are %v, expected %v", result.Clusters, names)
+		}
+		if result.TotalClusters != int32(len(names)) || result.TotalMatches != int32(len(names)) {
+			t.Errorf("totals are %d clusters and %d matches, expected %d", result.TotalClusters, result.TotalMatches, len(names))
+		}
+		if len(result.Placements) != len(names) {
+			t.Errorf("placements are for %d clusters, expected %d", len(result.Placements), len(names))
+		}
+	})
+
+	t.Run("satisfy only searches clusters in the request", func(t *testing.T) {
+		clusters := map[string]string{names[1]: "", names[5]: ""}
+		result, err := m.Satisfies(matches, matcher, false, clusters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{names[1], names[5]}
+		if !reflect.DeepEqual(result.Clusters, expected) || result.TotalClusters != 2 {
+			t.Errorf("clusters are %v of %d, expected %v", result.Clusters, result.TotalClusters, expected)
+		}
+	})
+
+	t.Run("explanations are merged from every graph", func(t *testing.T) {
+		result, err := m.Satisfies(loadJobspec(t, "jobspec-overlapping-groups.yaml"), matcher, true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Clusters) != 0 || result.TotalMismatches != int32(len(names)) {
+			t.Errorf("clusters are %v with %d mismatches, expected none", result.Clusters, result.TotalMismatches)
+		}
+		if len(result.Mismatches) != len(names) {
+			t.Errorf("explanations are for %d clusters, expected %d", len(result.Mismatches), len(names))
+		}
+	})
+
+	t.Run("capacity fans out to every graph", func(t *testing.T) {
+		capacity, err := m.Capacity(matches, matcher, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			if capacity[name] != 1 {
+				t.Errorf("capacity for %s is %d, expected 1", name, capacity[name])
+			}
+		}
+	})
+
+	t.Run("delete goes to the graph that owns the cluster", func(t *testing.T) {
+		err := m.DeleteCluster(names[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := m.Satisfies(matches, matcher, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := append(append([]string{}, names[:2]...), names[3:]...)
+		if !reflect.DeepEqual(result.Clusters, expected) {
+			t.Errorf("clusters are %v, expected %v", result.Clusters, expected)
+		}
+	})
+}
+
+func TestHashRing(t *testing.T) {
+	hosts := []string{"graph-0:50051", "graph-1:50051", "graph-2:50051"}
+	ring := newHashRing(hosts, defaultRingReplicas)
+
+	// Cluster names that differ by a character are spread across hosts
+	owned := map[string]int{}
+	for i := 0; i < 300; i++ {
+		owned[ring.owner(fmt.Sprintf(shardCluster, i))]++
+	}
+	for _, host := range hosts {
+		if owned[host] < 60 || owned[host] > 140 {
+			t.Errorf("host %s owns %d of 300 clusters, expected about 100", host, owned[host])
+		}
+	}
+
+	// Graphs on neighboring ports also share the clusters
+	for port := 50051; port < 50251; port += 10 {
+		neighbors := []string{}
+		for i := 0; i < shardCount; i++ {
+			neighbors = append(neighbors, fmt.Sprintf("127.0.0.1:%d", port+i))
+		}
+		neighborRing := newHashRing(neighbors, defaultRingReplicas)
+		owners := map[string]bool{}
+		for i := 0; i < shardMinimum; i++ {
+			owners[neighborRing.owner(fmt.Sprintf(shardCluster, i))] = true
+		}
+		if len(owners) < 2 {
+			t.Errorf("all clusters are owned by one of %v", neighbors)
+		}
+	}
+
+	// Adding a host only moves clusters to it
+	larger := newHashRing(append(hosts, "graph-3:50051"), defaultRingReplicas)
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf(shardCluster, i)
+		before, after := ring.owner(name), larger.owner(name)
+		if before != after && after != "graph-3:50051" {
+			t.Errorf("cluster %s moved from %s to %s", name, before, after)
+		}
+	}
+}
+
+// loadJobspec loads a jobspec from the slots examples
+func loadJobspec(t *testing.T, filename string) *js.Jobspec {
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(examplesDir, "slots", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jobspec
+}
+
+// shardClusters asks one graph directly for the clusters that match
+func shardClusters(t *testing.T, host, payload string) []string {
+	conn, client, err := dialGraph(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	response, err := client.Satisfy(context.Background(), &service.SatisfyRequest{Payload: payload, Matcher: "match"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(response.Clusters)
+	return response.Clusters
+}
@@ -0,0 +1,611 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/plugins/algorithms/shared"
+)
+
+// A slot that is not a resource type (e.g., a group of cores) is type slot
+var slotType = "slot"
+
+// The most orders of resource groups we try for one copy of a jobspec
+var maxGroupOrders = 120
+
+// A slotSearch allocates vertices to the resources of a jobspec. Each
+// resource is matched to vertices of its type, and the resources it has
+// (with) are matched below each of them, so counts are per vertex (e.g.,
+// 4 cores for each node). Vertices given to one resource are used, so slot
+// replicas, resources of the same type, and resource groups never share them.
+type slotSearch struct {
+	matcher algorithm.MatchAlgorithm
+	prune   bool
+
+	// Vertices that are used, and the order they were used in, so they can
+	// be released when a match below them fails
+	used      map[int]bool
+	allocated []allocation
+	serial    int
+
+	// Needs for each resource, parsed once and copied for each vertex, and
+	// the size of each resource type that one vertex of it needs below it
+	needs map[*v1.Resource]*types.ResourceNeeds
+	below map[*v1.Resource]map[string]int64
+
+	// Containment children of each vertex we visit, in search order, and
+	// the parent of each child (only with pruning)
+	children map[int][]*types.Vertex
+	parents  map[int]*types.Vertex
+
+	// The size of each resource type that is used below a vertex, so
+	// pruning skips subtrees that are used up (only counted with pruning)
+	usedBelow map[int]map[string]int64
+
+	// For each vertex and resource, the first child that can still have
+	// the resource, only kept when we count copies. We only skip children
+	// before it for copies after the first (skipExhausted), so that a search
+	// for one copy explains everything it did not find.
+	cursors       map[int]map[*v1.Resource]cursor
+	skipExhausted bool
+
+	// The group we are searching, and the slot in it we are filling
+	// (nested slots are part of it). Placements are kept for each replica.
+	group      string
+	slot       *v1.Resource
+	placements []types.SlotPlacement
+
+	// What was missing since the last replica was found, and the slot
+	// that could not be filled
+	unmet  map[string]bool
+	failed *types.Explanation
+}
+
+// An allocation is a vertex given to a resource, and the subsystem edges
+// that satisfy its requirements
+type allocation struct {
+	vertex *types.Vertex
+	edges  []*types.Edge
+	serial int
+}
+
+// A cursor is the first child of a vertex that can still have a resource.
+// Children before it had none with the allocations we had then, which stays
+// true while those allocations are kept (the last one is still at depth).
+type cursor struct {
+	child  int
+	depth  int
+	serial int
+}
+
+func newSlotSearch(matcher algorithm.MatchAlgorithm, prune bool) *slotSearch {
+	return &slotSearch{
+		matcher:   matcher,
+		prune:     prune,
+		used:      map[int]bool{},
+		needs:     map[*v1.Resource]*types.ResourceNeeds{},
+		below:     map[*v1.Resource]map[string]int64{},
+		children:  map[int][]*types.Vertex{},
+		parents:   map[int]*types.Vertex{},
+		usedBelow: map[int]map[string]int64{},
+	}
+}
+
+// A group is a top level resource of the jobspec
+type group struct {
+	name     string
+	resource *v1.Resource
+}
+
+// getGroups returns the resource groups of a jobspec to schedule, in a
+// consistent order. A group without a slot is one slot (like a jobspec with
+// none). If any slot is marked to schedule, groups without one are skipped.
+func getGroups(jobspec *v1.Jobspec) []group {
+	names := make([]string, 0, len(jobspec.Resources))
+	for name := range jobspec.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := []group{}
+	scheduled := []group{}
+	for _, name := range names {
+		resource := jobspec.Resources[name]
+		slots := findSlots(&resource)
+		if len(slots) == 0 {
+			resource = v1.Resource{Type: slotType, Replicas: 1, With: []v1.Resource{resource}}
+		}
+		g := group{name: name, resource: &resource}
+		groups = append(groups, g)
+		for _, slot := range slots {
+			if slot.Schedule {
+				scheduled = append(scheduled, g)
+				break
+			}
+		}
+	}
+	if len(scheduled) > 0 {
+		return scheduled
+	}
+	return groups
+}
+
+// findSlots returns the slots in a resource that are not in another slot
+func findSlots(resource *v1.Resource) []*v1.Resource {
+	if isSlot(resource) {
+		return []*v1.Resource{resource}
+	}
+	slots := []*v1.Resource{}
+	for i := range resource.With {
+		slots = append(slots, findSlots(&resource.With[i])...)
+	}
+	return slots
+}
+
+// isSlot determines if a resource is a slot, with replicas (or type slot)
+func isSlot(resource *v1.Resource) bool {
+	return resource.Replicas > 0 || resource.Type == slotType
+}
+
+// copiesOf returns the number of a resource that is needed: the replicas
+// of a slot, or the count of a resource
+func copiesOf(resource *v1.Resource) int32 {
+	copies := resource.Count
+	if isSlot(resource) {
+		copies = resource.Replicas
+	}
+	if copies <= 0 {
+		copies = 1
+	}
+	return copies
+}
+
+// orderGroups sorts groups from most to least constrained, by the number
+// of vertices that meet the requirements of their outermost resources.
+// Groups with the same number stay in order of their names.
+func (s *slotSearch) orderGroups(groups []group, dom *Subsystem) []group {
+	candidates := map[string]int{}
+	for _, g := range groups {
+		for _, resource := range outerResources(g.resource) {
+			for _, vtx := range dom.Vertices {
+				if vtx.Type != resource.Type {
+					continue
+				}
+				typeNeeds := s.resourceNeeds(resource).Subsystems[resource.Type].Copy()
+				if shared.CheckResource(typeNeeds, vtx, s.matcher) {
+					candidates[g.name] += 1
+				}
+			}
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return candidates[groups[i].name] < candidates[groups[j].name]
+	})
+	return groups
+}
+
+// outerResources returns the resources of a type in the graph that are
+// not below another, looking through slots of type slot
+func outerResources(resource *v1.Resource) []*v1.Resource {
+	if resource.Type != slotType {
+		return []*v1.Resource{resource}
+	}
+	resources := []*v1.Resource{}
+	for i := range resource.With {
+		resources = append(resources, outerResources(&resource.With[i])...)
+	}
+	return resources
+}
+
+// matchGroups matches every group for one copy of the jobspec, and returns
+// the placements. The search does not give vertices one group used to
+// another, so when a group cannot be matched after others, we try another
+// order. The first order (most constrained first) explains a failure.
+func (s *slotSearch) matchGroups(groups []group, root *types.Vertex) ([]types.SlotPlacement, bool) {
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	var failed *types.Explanation
+	s.placements = []types.SlotPlacement{}
+	mark := s.mark()
+	for tries := 0; tries < maxGroupOrders; tries++ {
+		placements := []types.SlotPlacement{}
+		at := -1
+		for i, index := range order {
+			if !s.matchGroup(groups[index], root) {
+				at = i
+				break
+			}
+			placements = append(placements, s.placements...)
+		}
+		if at < 0 {
+			return placements, true
+		}
+		if failed == nil {
+			failed = s.failed
+		}
+		s.release(mark)
+
+		// A group that cannot be matched first cannot be matched in any order
+		if at == 0 || !nextOrder(order, at) {
+			break
+		}
+		rlog.Debugf("         Group %s cannot be matched, trying another order\n", groups[order[at]].name)
+	}
+	s.failed = failed
+	return nil, false
+}
+
+// nextOrder changes an order to the next one (in lexicographic order) that
+// does not start the same way up to and including an index. The orders
+// we skip fail at the same group. It returns false if there is none.
+func nextOrder(order []int, at int) bool {
+	sort.Sort(sort.Reverse(sort.IntSlice(order[at+1:])))
+	i := len(order) - 2
+	for i >= 0 && order[i] >= order[i+1] {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+	j := len(order) - 1
+	for order[j] <= order[i] {
+		j--
+	}
+	order[i], order[j] = order[j], order[i]
+	sort.Ints(order[i+1:])
+	return true
+}
+
+// matchGroup matches every slot of a group, starting at the root of the
+// cluster. The placements for the replicas are kept.
+func (s *slotSearch) matchGroup(g group, root *types.Vertex) bool {
+	s.group = g.name
+	s.slot = nil
+	s.placements = []types.SlotPlacement{}
+	s.failed = nil
+	s.unmet = map[string]bool{}
+	if s.matchResource(g.resource, []*types.Vertex{root}) {
+		return true
+	}
+
+	// If the search did not get to a slot, none of it was found
+	if s.failed == nil {
+		slot := findSlots(g.resource)[0]
+		s.explain(slot, copiesOf(slot), 0)
+	}
+	return false
+}
+
+// label returns the label for the placements of a slot
+func (s *slotSearch) label(slot *v1.Resource) string {
+	if slot.Label != "" {
+		return slot.Label
+	}
+	return s.group
+}
+
+// matchResource matches the copies of a resource to vertices at (or below)
+// the vertices we start from. A slot that is not a resource type groups
+// the resources it has, and they are matched below the same vertices.
+func (s *slotSearch) matchResource(resource *v1.Resource, start []*types.Vertex) bool {
+	needed := copiesOf(resource)
+	found := int32(0)
+
+	// The outermost slot is the one we record placements for
+	if s.slot == nil && isSlot(resource) {
+		s.slot = resource
+		s.unmet = map[string]bool{}
+		defer func() { s.slot = nil }()
+	}
+
+	if resource.Type == slotType {
+		for found < needed {
+			mark := s.mark()
+			isMatch := true
+			for i := range resource.With {
+				if !s.matchResource(&resource.With[i], start) {
+					isMatch = false
+					break
+				}
+			}
+			if !isMatch {
+				s.release(mark)
+				break
+			}
+			s.found(resource, mark)
+			found += 1
+		}
+	} else {
+		for _, vtx := range start {
+			if found >= needed {
+				break
+			}
+
+			// What we find is below the vertex, so it needs all that is left
+			if s.prune && !s.has(vtx, resource.Type, int64(needed-found)) {
+				continue
+			}
+			s.visit(resource, vtx, &found, needed)
+		}
+	}
+	if found >= needed {
+		return true
+	}
+	s.unmet[fmt.Sprintf("%s=%d", resource.Type, needed-found)] = true
+	if resource == s.slot {
+		s.explain(resource, needed, found)
+	}
+	return false
+}
+
+// visit looks for vertices of a resource type at and below a vertex. A
+// vertex of the type is not searched below, since its resources are
+// matched below it.
+func (s *slotSearch) visit(resource *v1.Resource, vtx *types.Vertex, found *int32, needed int32) {
+	if vtx.Type == resource.Type {
+		if !s.used[vtx.Identifier] && s.matchVertex(resource, vtx) {
+			*found += s.size(resource, vtx)
+		}
+		return
+	}
+
+	// A vertex of the type is below this one, and what it needs is below it
+	if s.prune {
+		if !s.has(vtx, resource.Type, 1) {
+			return
+		}
+		for resourceType, count := range s.belowNeeds(resource) {
+			if !s.has(vtx, resourceType, count) {
+				return
+			}
+		}
+	}
+	children := s.childrenOf(vtx)
+	for i := s.cursor(vtx, resource); i < len(children); i++ {
+		if *found >= needed {
+			return
+		}
+		before := *found
+		s.visit(resource, children[i], found, needed)
+		if *found == before {
+			s.exhausted(vtx, resource, i)
+		}
+	}
+}
+
+// belowNeeds returns the size of each resource type that one vertex of a
+// resource needs below it. We only know one vertex is needed for a count
+// (a vertex can be larger than one), so that is what we count for it.
+func (s *slotSearch) belowNeeds(resource *v1.Resource) map[string]int64 {
+	below, ok := s.below[resource]
+	if ok {
+		return below
+	}
+	below = map[string]int64{}
+	for i := range resource.With {
+		with := &resource.With[i]
+		copies := int64(copiesOf(with))
+		perVertex := int64(1)
+		if isSlot(with) {
+			perVertex = copies
+		}
+		if with.Type != slotType {
+			below[with.Type] += copies
+		}
+		for resourceType, count := range s.belowNeeds(with) {
+			below[resourceType] += perVertex * count
+		}
+	}
+	s.below[resource] = below
+	return below
+}
+
+// has determines if a vertex is the resource type, or has at least a count
+// of the type below it that is not used
+func (s *slotSearch) has(vtx *types.Vertex, resourceType string, count int64) bool {
+	if count < 1 {
+		count = 1
+	}
+	return vtx.Contains(resourceType, count+s.usedBelow[vtx.Identifier][resourceType])
+}
+
+// cursor returns the first child of a vertex to search for a resource
+func (s *slotSearch) cursor(vtx *types.Vertex, resource *v1.Resource) int {
+	if !s.skipExhausted {
+		return 0
+	}
+	return s.validCursor(vtx, resource)
+}
+
+// validCursor returns the cursor for a vertex and resource, or the first
+// child if an allocation it depends on was released
+func (s *slotSearch) validCursor(vtx *types.Vertex, resource *v1.Resource) int {
+	c, ok := s.cursors[vtx.Identifier][resource]
+	if !ok {
+		return 0
+	}
+	if c.depth > 0 && (c.depth > len(s.allocated) || s.allocated[c.depth-1].serial != c.serial) {
+		return 0
+	}
+	return c.child
+}
+
+// countCopies keeps cursors, so counting copies after the first is linear
+func (s *slotSearch) countCopies() {
+	s.cursors = map[int]map[*v1.Resource]cursor{}
+}
+
+// exhausted records that a child of a vertex did not have a resource. If
+// every child before it did not either, the cursor moves past it.
+func (s *slotSearch) exhausted(vtx *types.Vertex, resource *v1.Resource, child int) {
+	if s.cursors == nil || s.validCursor(vtx, resource) != child {
+		return
+	}
+	cursors, ok := s.cursors[vtx.Identifier]
+	if !ok {
+		cursors = map[*v1.Resource]cursor{}
+		s.cursors[vtx.Identifier] = cursors
+	}
+	c := cursor{child: child + 1, depth: len(s.allocated)}
+	if c.depth > 0 {
+		c.serial = s.allocated[c.depth-1].serial
+	}
+	cursors[resource] = c
+}
+
+// countUsed adds the size of a vertex that is used (or released, with a
+// sign of -1) to each vertex above it
+func (s *slotSearch) countUsed(vtx *types.Vertex, sign int64) {
+	if !s.prune {
+		return
+	}
+	size := sign * vertexSize(vtx)
+	for parent := s.parents[vtx.Identifier]; parent != nil; parent = s.parents[parent.Identifier] {
+		counts, ok := s.usedBelow[parent.Identifier]
+		if !ok {
+			counts = map[string]int64{}
+			s.usedBelow[parent.Identifier] = counts
+		}
+		counts[vtx.Type] += size
+	}
+}
+
+// childrenOf returns the containment children of a vertex, sorted once
+// since copies (and resources) of a jobspec search the same vertices
+func (s *slotSearch) childrenOf(vtx *types.Vertex) []*types.Vertex {
+	children, ok := s.children[vtx.Identifier]
+	if ok {
+		return children
+	}
+	children = []*types.Vertex{}
+	for _, edge := range sortedEdges(vtx.Edges) {
+
+		// Only interested in containment subsystem node
+		if edge.Subsystem == types.DefaultDominantSubsystem {
+			children = append(children, edge.Vertex)
+			if s.prune {
+				s.parents[edge.Vertex.Identifier] = vtx
+			}
+		}
+	}
+	s.children[vtx.Identifier] = children
+	return children
+}
+
+// size is how much of a resource a vertex provides. A vertex is one
+// replica of a slot, and can be more than one of a count (e.g., memory).
+func (s *slotSearch) size(resource *v1.Resource, vtx *types.Vertex) int32 {
+	if isSlot(resource) || vtx.Size <= 0 {
+		return 1
+	}
+	return vtx.Size
+}
+
+// matchVertex determines if a vertex meets the requirements of a resource,
+// and has the resources below it that the resource has. If it does, the
+// vertex (and those below it) are used.
+func (s *slotSearch) matchVertex(resource *v1.Resource, vtx *types.Vertex) bool {
+	rlog.Debugf("           Checking vertex %s for resource %s\n", vtx.NodeId, resource.Type)
+	needs := s.resourceNeeds(resource)
+	typeNeeds := needs.Subsystems[resource.Type].Copy()
+	if !shared.CheckResource(typeNeeds, vtx, s.matcher) {
+		unsatisfied := types.ResourceNeeds{Subsystems: map[string]types.MatchAlgorithmNeeds{resource.Type: typeNeeds}}
+		for _, need := range unsatisfied.Remaining() {
+			s.unmet[need] = true
+		}
+		return false
+	}
+
+	mark := s.mark()
+	s.used[vtx.Identifier] = true
+	s.countUsed(vtx, 1)
+	s.serial += 1
+	s.allocated = append(s.allocated, allocation{vertex: vtx, edges: shared.SatisfyingEdges(needs, vtx), serial: s.serial})
+	for i := range resource.With {
+		if !s.matchResource(&resource.With[i], []*types.Vertex{vtx}) {
+			s.release(mark)
+			return false
+		}
+	}
+	s.found(resource, mark)
+	return true
+}
+
+// resourceNeeds returns the needs for a resource, parsed once
+func (s *slotSearch) resourceNeeds(resource *v1.Resource) *types.ResourceNeeds {
+	needs, ok := s.needs[resource]
+	if !ok {
+		needs = shared.GetResourceNeeds(resource, s.matcher)
+		s.needs[resource] = needs
+	}
+	return needs
+}
+
+// A mark is where we are in the allocations and placements, to release
+// everything after it
+type mark struct {
+	allocated  int
+	placements int
+}
+
+func (s *slotSearch) mark() mark {
+	return mark{allocated: len(s.allocated), placements: len(s.placements)}
+}
+
+// release frees the vertices used (and placements found) after a mark
+func (s *slotSearch) release(m mark) {
+	for _, used := range s.allocated[m.allocated:] {
+		delete(s.used, used.vertex.Identifier)
+		s.countUsed(used.vertex, -1)
+	}
+	s.allocated = s.allocated[:m.allocated]
+	s.placements = s.placements[:m.placements]
+}
+
+// found records a placement for a replica of the slot we are filling,
+// with the vertices used since the mark. Other resources are part of it.
+func (s *slotSearch) found(resource *v1.Resource, m mark) {
+	if resource != s.slot {
+		return
+	}
+	label := s.label(resource)
+	replica := int32(0)
+	for _, placement := range s.placements {
+		if placement.Slot == label {
+			replica += 1
+		}
+	}
+	placement := types.NewSlotPlacement(label, replica)
+	for _, used := range s.allocated[m.allocated:] {
+		placement.Vertices = append(placement.Vertices, used.vertex.NodeId)
+		for _, edge := range used.edges {
+			placement.AddSubsystemVertex(edge.Subsystem, edge.Vertex.NodeId)
+		}
+	}
+	s.placements = append(s.placements, *placement)
+	s.unmet = map[string]bool{}
+	rlog.Debugf("         Found replica %d of slot %s\n", replica, label)
+}
+
+// explain describes a slot that could not be filled, with what was
+// missing since the last replica that was found
+func (s *slotSearch) explain(slot *v1.Resource, needed, found int32) {
+	unmet := []string{}
+	for need := range s.unmet {
+		unmet = append(unmet, need)
+	}
+	sort.Strings(unmet)
+	s.failed = &types.Explanation{
+		Reason:   types.ReasonSlotUnsatisfied,
+		Resource: s.label(slot),
+		Needed:   needed,
+		Found:    found,
+		Unmet:    unmet,
+	}
+}
@@ -0,0 +1,189 @@
+package memory_test
+
+// Check the memory graph depth first search with jobspecs that have more
+// than one resource group, nested slots, types at more than one level,
+// and count ranges. Each jobspec has the answer we expect for the self
+// example cluster, with and without pruning.
+// go test -run TestSlots ./plugins/backends/memory
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/plugins/backends/memory"
+)
+
+const (
+	clusterName = "cluster-red"
+	examples    = "../../../docs/examples"
+)
+
+// The cluster has three nodes, each with one socket of 12 cores. node0
+// and node1 are aarch64, and node2 is x86_64. The search visits them in
+// the order of their identifiers in the graph: node1, node0, then node2.
+// The slots are the nodes for each replica of a slot, by label, and the
+// sizes are for count ranges, by resource path.
+var slotTests = []struct {
+	jobspec  string
+	isMatch  bool
+	capacity int32
+	slots    map[string][]string
+	sizes    map[string]int32
+}{
+	{
+		jobspec:  "jobspec-two-groups.yaml",
+		isMatch:  true,
+		capacity: 1,
+		slots:    map[string][]string{"leader": {"node1"}, "workers": {"node0", "node2"}},
+	},
+	{
+		jobspec: "jobspec-overlapping-groups.yaml",
+		isMatch: false,
+	},
+	{
+		jobspec:  "jobspec-nested-slots.yaml",
+		isMatch:  true,
+		capacity: 1,
+		slots:    map[string][]string{"ranks": {"node1", "node0"}},
+	},
+	{
+		jobspec: "jobspec-nested-slots-too-big.yaml",
+		isMatch: false,
+	},
+	{
+		jobspec:  "jobspec-repeated-types.yaml",
+		isMatch:  true,
+		capacity: 3,
+		slots:    map[string][]string{"node": {"node1"}},
+	},
+	{
+		jobspec: "jobspec-repeated-types-too-big.yaml",
+		isMatch: false,
+	},
+	{
+		jobspec:  "jobspec-labeled-slots.yaml",
+		isMatch:  true,
+		capacity: 1,
+		slots:    map[string][]string{"leader": {"node2"}, "workers": {"node1", "node0"}},
+	},
+	{
+		jobspec:  "jobspec-range-nodes.yaml",
+		isMatch:  true,
+		capacity: 3,
+		slots:    map[string][]string{"ranks": {"node1", "node0", "node2"}},
+		sizes:    map[string]int32{"ranks": 3},
+	},
+	{
+		jobspec:  "jobspec-range-cores.yaml",
+		isMatch:  true,
+		capacity: 1,
+		slots:    map[string][]string{"ranks": {"node1", "node0"}},
+		sizes:    map[string]int32{"ranks/core": 12},
+	},
+	{
+		jobspec:  "jobspec-range-preferred.yaml",
+		isMatch:  true,
+		capacity: 3,
+		slots:    map[string][]string{"ranks": {"node1", "node0"}},
+		sizes:    map[string]int32{"ranks": 2},
+	},
+	{
+		jobspec: "jobspec-range-too-big.yaml",
+		isMatch: false,
+	},
+	{
+		jobspec:  "jobspec-constrained-groups.yaml",
+		isMatch:  true,
+		capacity: 1,
+		slots:    map[string][]string{"a": {"node0", "node2"}, "b": {"node1"}},
+	},
+	{
+		jobspec:  "jobspec-reordered-groups.yaml",
+		isMatch:  true,
+		capacity: 1,
+		slots:    map[string][]string{"a": {"node0,node2"}, "b": {"node1"}},
+	},
+}
+
+func TestSlots(t *testing.T) {
+	g := memory.NewGraph()
+	nodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examples, "match-algorithms", "self", "cluster-nodes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.LoadClusterNodes(clusterName, &nodes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Placements have the identifiers of vertices, and we check node names
+	names := map[string]string{}
+	for id, node := range nodes.Graph.Nodes {
+		nodeType, _ := node.Metadata.GetStringElement("type")
+		if nodeType == "node" {
+			names[id], _ = node.Metadata.GetStringElement("name")
+		}
+	}
+	cluster := g.Clusters[clusterName]
+	matcher := algorithm.GetOrFail("match")
+
+	for _, prune := range []bool{false, true} {
+		g.SetPruning(prune)
+		for _, test := range slotTests {
+			name := strings.TrimSuffix(test.jobspec, ".yaml")
+			if prune {
+				name += "/pruning"
+			}
+			t.Run(name, func(t *testing.T) {
+				jobspec, err := js.LoadJobspecYaml(filepath.Join(examples, "slots", test.jobspec))
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := cluster.DFSForMatch(jobspec, matcher)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.IsMatch != test.isMatch {
+					t.Fatalf("match is %t, expected %t (%v)", result.IsMatch, test.isMatch, result.Explanations)
+				}
+				slots := placedNodes(result.Placements, names)
+				if test.isMatch && !reflect.DeepEqual(slots, test.slots) {
+					t.Errorf("slots are %v, expected %v", slots, test.slots)
+				}
+				if (len(test.sizes) > 0 || len(result.Sizes) > 0) && !reflect.DeepEqual(result.Sizes, test.sizes) {
+					t.Errorf("sizes are %v, expected %v", result.Sizes, test.sizes)
+				}
+				capacity, err := cluster.DFSForCapacity(jobspec, matcher)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if capacity != test.capacity {
+					t.Errorf("capacity is %d, expected %d", capacity, test.capacity)
+				}
+			})
+		}
+	}
+}
+
+// placedNodes returns the names of the nodes for each replica of a slot,
+// by label. A replica with more than one node has them joined by a comma.
+func placedNodes(placements []types.SlotPlacement, names map[string]string) map[string][]string {
+	slots := map[string][]string{}
+	for _, placement := range placements {
+		placed := []string{}
+		for _, vertex := range placement.Vertices {
+			name, ok := names[vertex]
+			if ok {
+				placed = append(placed, name)
+			}
+		}
+		slots[placement.Slot] = append(slots[placement.Slot], strings.Join(placed, ","))
+	}
+	return slots
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/converged-computing/jsongraph-go/jsongraph/metadata"
 	"github.com/converged-computing/rainbow/pkg/types"
@@ -21,13 +22,14 @@ func NewSubsystem(name string) *Subsystem {
 
 	// Create a top level vertex for all clusters that will be added
 	// Question: should the root be above the subsystems?
-	s.AddNode("", name, 1, "", metadata.Metadata{}, false)
+	s.AddNode("", "", name, 1, "", metadata.Metadata{}, false)
 	return &s
 }
 
 // AddNode (a physical node) as a vertex, return the vertex id
+// The nodeId is the identifier from the JGF, if there is one
 func (s *Subsystem) AddNode(
-	lookupName, typ string,
+	lookupName, nodeId, typ string,
 	size int32,
 	unit string,
 	meta metadata.Metadata,
@@ -47,6 +49,7 @@ func (s *Subsystem) AddNode(
 	// Add the subsystem node
 	s.Vertices[id] = &types.Vertex{
 		Identifier: id,
+		NodeId:     nodeId,
 		Edges:      newEdges,
 		Size:       size,
 		Type:       typ,
@@ -154,6 +157,60 @@ func (s *Subsystem) GetConnections(src int) []int {
 	return conns
 }
 
+// CountDescendants updates each vertex with the total size of each resource
+// type below it. This needs to be called when containment edges change.
+// A vertex without a size counts as one, as it does for the search.
+func (s *Subsystem) CountDescendants() {
+	visited := map[int]bool{}
+
+	var countVertex func(vtx *types.Vertex) map[string]int64
+	countVertex = func(vtx *types.Vertex) map[string]int64 {
+		if visited[vtx.Identifier] {
+			return vtx.Descendants
+		}
+		visited[vtx.Identifier] = true
+		vtx.Descendants = map[string]int64{}
+
+		for _, edge := range vtx.Edges {
+			if edge.Subsystem != s.Name {
+				continue
+			}
+			child := edge.Vertex
+			vtx.Descendants[child.Type] += vertexSize(child)
+			for resourceType, count := range countVertex(child) {
+				vtx.Descendants[resourceType] += count
+			}
+		}
+		return vtx.Descendants
+	}
+	for _, vtx := range s.Vertices {
+		countVertex(vtx)
+	}
+}
+
+// vertexSize is the size a vertex counts for, at least one
+func vertexSize(vtx *types.Vertex) int64 {
+	if vtx.Size <= 0 {
+		return 1
+	}
+	return int64(vtx.Size)
+}
+
+// sortedEdges returns edges ordered by vertex identifier, so
+// that a search (and the placements it finds) is reproducible
+func sortedEdges(edges map[int]*types.Edge) []*types.Edge {
+	ids := make([]int, 0, len(edges))
+	for id := range edges {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	sorted := make([]*types.Edge, 0, len(edges))
+	for _, id := range ids {
+		sorted = append(sorted, edges[id])
+	}
+	return sorted
+}
+
 func (s *Subsystem) CountVertices() int {
 	return len(s.Vertices)
 }
@@ -33,9 +33,37 @@ type Metrics struct {
 	Writes   int64 `json:"writes"`
 	Reads    int64 `json:"reads"`
 
+	// Satisfy results found (or not) in the cache
+	CacheHits   int64 `json:"cacheHits"`
+	CacheMisses int64 `json:"cacheMisses"`
+
 	// Courtesy to print the subsystem name
 	Name string `json:"name"`
 
 	// Resource specific metrics
 	ResourceCounts map[string]int64
 }
+
+// A MatchResult is the result of searching one cluster graph
+type MatchResult struct {
+	IsMatch bool
+
+	// Reasons the cluster did not match, if it did not
+	Explanations []types.Explanation
+
+	// Vertices chosen for each slot replica, if it did
+	Placements []types.SlotPlacement
+
+	// The size chosen for each count range, by resource path, if it did
+	Sizes map[string]int32
+
+	// Copies of the jobspec that fit, only set when counting capacity
+	Capacity int32
+}
+
+func newMatchResult() *MatchResult {
+	return &MatchResult{
+		Explanations: []types.Explanation{},
+		Placements:   []types.SlotPlacement{},
+	}
+}
@@ -0,0 +1,31 @@
+package sqlite_test
+
+// Check that the sqlite backend passes the conformance checks that every
+// graph backend can run, with a database file that is removed after.
+// go test -run TestConformance ./plugins/backends/sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend/backendtest"
+	"github.com/converged-computing/rainbow/plugins/backends/sqlite"
+
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+func TestConformance(t *testing.T) {
+	graphDB := sqlite.SQLiteGraph{}
+	err := graphDB.Init(map[string]string{"file": filepath.Join(t.TempDir(), "conformance.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backendtest.TestBackend(graphDB, backendtest.Options{
+		Examples: "../../../docs/examples",
+		Matcher:  algorithm.GetOrFail("match"),
+	})
+	if err != nil {
+		t.Errorf("sqlite backend is not conformant:\n%s", err)
+	}
+}
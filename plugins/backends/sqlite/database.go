@@ -0,0 +1,191 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/types"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Subsystems belong to a cluster, and vertices to a subsystem. An edge
+// belongs to the subsystem of its target, so containment edges in the
+// dominant subsystem are the only ones followed by a search, and edges
+// from the dominant subsystem to another are found by its name.
+// Deleting a subsystem deletes its vertices, and their edges.
+var schema = `
+CREATE TABLE IF NOT EXISTS subsystems (
+	cluster TEXT NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (cluster, name)
+);
+
+CREATE TABLE IF NOT EXISTS vertices (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	cluster TEXT NOT NULL,
+	subsystem TEXT NOT NULL,
+	nid TEXT NOT NULL,
+	type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	unit TEXT,
+	metadata TEXT,
+	UNIQUE (cluster, subsystem, nid),
+	FOREIGN KEY (cluster, subsystem) REFERENCES subsystems(cluster, name) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS vertices_type ON vertices(cluster, subsystem, type);
+
+CREATE TABLE IF NOT EXISTS edges (
+	source INTEGER NOT NULL REFERENCES vertices(id) ON DELETE CASCADE,
+	target INTEGER NOT NULL REFERENCES vertices(id) ON DELETE CASCADE,
+	subsystem TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS edges_source ON edges(source, subsystem);
+CREATE INDEX IF NOT EXISTS edges_target ON edges(target);
+
+CREATE TABLE IF NOT EXISTS states (
+	cluster TEXT NOT NULL PRIMARY KEY,
+	state TEXT
+);
+`
+
+// connect opens the database and creates the tables, if needed
+// Foreign keys are needed to delete in cascade, and the write ahead
+// log lets a client read while rainbow writes.
+func connect(filepath string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000", filepath)
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Exec(schema)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// hasSubsystem determines if a cluster has a subsystem
+func hasSubsystem(tx *sql.Tx, cluster, subsystem string) (bool, error) {
+	var count int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM subsystems WHERE cluster = ? AND name = ?",
+		cluster, subsystem,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// addNodes adds a subsystem for a cluster, and its vertices. We return a
+// lookup of the JGF node ids to vertex ids.
+func addNodes(tx *sql.Tx, cluster string, nodes *jgf.JsonGraph, subsystem string) (map[string]int64, error) {
+	lookup := map[string]int64{}
+
+	// Let's be pedantic - no clusters allowed without nodes or edges
+	nNodes, nEdges, err := graph.ValidateNodes(nodes)
+	if err != nil {
+		return lookup, err
+	}
+	exists, err := hasSubsystem(tx, cluster, subsystem)
+	if err != nil {
+		return lookup, err
+	}
+	if exists {
+		return lookup, fmt.Errorf("subsystem %s already exists for cluster %s", subsystem, cluster)
+	}
+	log.Printf("Preparing to load %d nodes and %d edges\n", nNodes, nEdges)
+
+	_, err = tx.Exec("INSERT INTO subsystems (cluster, name) VALUES (?, ?)", cluster, subsystem)
+	if err != nil {
+		return lookup, err
+	}
+	statement, err := tx.Prepare(
+		"INSERT INTO vertices (cluster, subsystem, nid, type, size, unit, metadata) VALUES (?, ?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return lookup, err
+	}
+	defer statement.Close()
+
+	// Add nodes in a consistent order so vertex identifiers are reproducible
+	nids := make([]string, 0, len(nodes.Graph.Nodes))
+	for nid := range nodes.Graph.Nodes {
+		nids = append(nids, nid)
+	}
+	sort.Strings(nids)
+
+	for _, nid := range nids {
+		node := nodes.Graph.Nodes[nid]
+		resource := types.NewResource(node)
+		meta, err := json.Marshal(node.Metadata)
+		if err != nil {
+			return lookup, err
+		}
+		result, err := statement.Exec(cluster, subsystem, nid, resource.Type, resource.Size, resource.Unit, string(meta))
+		if err != nil {
+			return lookup, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return lookup, err
+		}
+		lookup[nid] = id
+	}
+	return lookup, nil
+}
+
+// addEdges adds containment edges between vertices. The source of an edge
+// not in the lookup is looked for in the dominant subsystem, and we
+// return how many of those there are.
+func addEdges(
+	tx *sql.Tx,
+	cluster string,
+	nodes *jgf.JsonGraph,
+	subsystem string,
+	lookup map[string]int64,
+) (int, error) {
+
+	statement, err := tx.Prepare("INSERT INTO edges (source, target, subsystem) VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	count := 0
+	for _, edge := range nodes.Graph.Edges {
+
+		// We are currently just saving one direction "x contains y"
+		if edge.Relation != types.ContainsRelation {
+			continue
+		}
+		dest, ok := lookup[edge.Target]
+		if !ok {
+			return count, fmt.Errorf("destination %s is defined as an edge, but missing as node in graph", edge.Target)
+		}
+		src, ok := lookup[edge.Source]
+		if !ok && subsystem != types.DefaultDominantSubsystem {
+			err := tx.QueryRow(
+				"SELECT id FROM vertices WHERE cluster = ? AND subsystem = ? AND nid = ?",
+				cluster, types.DefaultDominantSubsystem, edge.Source,
+			).Scan(&src)
+			if err == sql.ErrNoRows {
+				return count, fmt.Errorf("edge %s->%s is not internal, and not connected to the dominant subsystem", edge.Source, edge.Target)
+			}
+			if err != nil {
+				return count, err
+			}
+			count += 1
+		} else if !ok {
+			return count, fmt.Errorf("source %s is defined as an edge, but missing as node in graph", edge.Source)
+		}
+		_, err := statement.Exec(src, dest, subsystem)
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,517 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	v1 "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	"github.com/converged-computing/jsongraph-go/jsongraph/metadata"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	rspec "github.com/converged-computing/rainbow/pkg/jobspec"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/plugins/algorithms/match"
+	"github.com/converged-computing/rainbow/plugins/algorithms/shared"
+)
+
+// A matchResult is the result of searching one cluster
+type matchResult struct {
+	isMatch bool
+
+	// Reasons the cluster did not match, if it did not
+	explanations []types.Explanation
+
+	// Vertices chosen for each slot replica, if it did
+	placements []types.SlotPlacement
+
+	// The size chosen for each count range, by resource path, if it did
+	sizes map[string]int32
+
+	// Copies of the jobspec that fit, only set when counting capacity
+	capacity int32
+}
+
+// slotQuery finds every vertex of a slot type in a cluster, and (with a
+// recursive traversal of containment edges) the vertices below it. We
+// return each vertex with the slot vertex (root) it is under, in order.
+var slotQuery = `
+WITH RECURSIVE slot(root, id) AS (
+	SELECT id, id FROM vertices
+	WHERE cluster = ? AND subsystem = ? AND type = ?
+	UNION ALL
+	SELECT slot.root, edges.target FROM slot
+	JOIN edges ON edges.source = slot.id AND edges.subsystem = ?
+)
+SELECT slot.root, vertices.id, vertices.nid, vertices.type, vertices.size, vertices.metadata
+FROM slot JOIN vertices ON vertices.id = slot.id
+WHERE vertices.type IN (%s)
+ORDER BY slot.root, vertices.id
+`
+
+// subsystemQuery finds the edges from dominant vertices of some types
+// in a cluster to vertices in other subsystems
+var subsystemQuery = `
+SELECT edges.source, edges.subsystem, target.id, target.nid, target.type, target.size, target.unit, target.metadata
+FROM edges
+JOIN vertices AS source ON source.id = edges.source
+JOIN vertices AS target ON target.id = edges.target
+WHERE source.cluster = ? AND source.subsystem = ? AND edges.subsystem != ? AND source.type IN (%s)
+`
+
+// internalQuery finds the vertices below the subsystem vertices that
+// dominant vertices of some types are connected to, with the contains
+// edges (within the subsystem) to each of them
+var internalQuery = `
+WITH RECURSIVE below(id) AS (
+	SELECT edges.target FROM edges
+	JOIN vertices AS source ON source.id = edges.source
+	WHERE source.cluster = ? AND source.subsystem = ? AND edges.subsystem != ? AND source.type IN (%s)
+	UNION
+	SELECT edges.target FROM below
+	JOIN edges ON edges.source = below.id
+)
+SELECT edges.source, target.id, target.nid, target.type, target.size, target.unit, target.metadata
+FROM below
+JOIN edges ON edges.source = below.id
+JOIN vertices AS target ON target.id = edges.target
+`
+
+// moldCluster searches a cluster for a jobspec at the largest sizes of
+// its count ranges that fit. If the smallest sizes do not fit, the
+// explanations are for them.
+func moldCluster(
+	conn *sql.DB,
+	cluster string,
+	jobspec *v1.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+) (*matchResult, error) {
+
+	if !rspec.HasRanges(jobspec) {
+		return searchCluster(conn, cluster, jobspec, matcher, false)
+	}
+	minimum, err := rspec.WithSizes(jobspec, map[string]int32{})
+	if err != nil {
+		return &matchResult{}, err
+	}
+	result, err := searchCluster(conn, cluster, minimum, matcher, false)
+	if err != nil || !result.isMatch {
+		return result, err
+	}
+	sizes, err := rspec.Mold(jobspec, func(molded *v1.Jobspec) (bool, error) {
+		result, err := searchCluster(conn, cluster, molded, matcher, false)
+		return result.isMatch, err
+	})
+	if err != nil {
+		return result, err
+	}
+	molded, err := rspec.WithSizes(jobspec, sizes)
+	if err != nil {
+		return result, err
+	}
+	result, err = searchCluster(conn, cluster, molded, matcher, false)
+	result.sizes = sizes
+	return result, err
+}
+
+// searchCluster determines if a cluster can satisfy a jobspec. For each
+// resource group, we find the slot, and then the vertices of that type
+// that have what the slot needs below them. Subsystem requirements are
+// checked the same way as the memory backend, with the options of the
+// matcher. If countAll is true, we
+// count every slot, and the result capacity is the number of copies
+// of the jobspec that fit. Count ranges are at their smallest sizes.
+func searchCluster(
+	conn *sql.DB,
+	cluster string,
+	jobspec *v1.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	countAll bool,
+) (*matchResult, error) {
+
+	result := &matchResult{
+		explanations: []types.Explanation{},
+		placements:   []types.SlotPlacement{},
+		capacity:     math.MaxInt32,
+	}
+
+	jobspec = rspec.AtMinimum(jobspec)
+
+	// Some matchers read the jobspec (e.g., an artifact it references)
+	matcher, err := algorithm.ForJobspec(matcher, jobspec)
+	if err != nil {
+		return result, err
+	}
+	counts, err := resourceCounts(conn, cluster)
+	if err != nil {
+		return result, err
+	}
+
+	// Do a quick top level count for resource types. We check all
+	// totals (instead of returning at the first) so the explanation is complete
+	for _, slotCount := range graph.ExtractResourceSlots(jobspec) {
+		actual, ok := counts[slotCount.Name]
+		needed := slotCount.Count
+		if !ok {
+			result.explanations = append(result.explanations, types.Explanation{
+				Reason:   types.ReasonResourceMissing,
+				Resource: slotCount.Name,
+				Needed:   needed,
+			})
+		} else if int32(actual) < needed {
+			result.explanations = append(result.explanations, types.Explanation{
+				Reason:   types.ReasonResourceCount,
+				Resource: slotCount.Name,
+				Needed:   needed,
+				Found:    int32(actual),
+			})
+		}
+		if needed > 0 && int32(actual/int64(needed)) < result.capacity {
+			result.capacity = int32(actual / int64(needed))
+		}
+	}
+	if len(result.explanations) > 0 {
+		result.capacity = 0
+		return result, nil
+	}
+
+	// If we don't have jobspec.Resources, the top level counts are all we know
+	resources := jobspec.GetScheduledNamedSlots()
+	labels := make([]string, 0, len(resources))
+	for label := range resources {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		resource := resources[label]
+		slotLabel := label
+		if slotLabel == "" {
+			slotLabel = resource.Type
+		}
+		found, placements, unmet, err := searchSlots(conn, cluster, slotLabel, resource, matcher, countAll)
+		if err != nil {
+			return result, err
+		}
+
+		// Cut out early if one resource group cannot be matched
+		if found == 0 || found < unmet.Needed {
+			result.capacity = 0
+			result.explanations = append(result.explanations, types.Explanation{
+				Reason:   types.ReasonSlotUnsatisfied,
+				Resource: slotLabel,
+				Needed:   unmet.Needed,
+				Found:    found,
+				Unmet:    unmet.Remaining(),
+			})
+			return result, nil
+		}
+		result.placements = append(result.placements, placements...)
+
+		// The group with the fewest copies limits the capacity
+		copies := found / unmet.Needed
+		if copies < result.capacity {
+			result.capacity = copies
+		}
+	}
+	result.isMatch = true
+	return result, nil
+}
+
+// searchSlots counts the slots for a resource group in a cluster, up to
+// the replicas needed (or all of them, if countAll is true). We return
+// placements for the replicas needed, and the needs of the last slot
+// that was not satisfied, for explanation.
+func searchSlots(
+	conn *sql.DB,
+	cluster, label string,
+	resource v1.Resource,
+	matcher algorithm.MatchAlgorithm,
+	countAll bool,
+) (int32, []types.SlotPlacement, *types.ResourceNeeds, error) {
+
+	placements := []types.SlotPlacement{}
+	slot, ok := findSlot(resource)
+	if !ok {
+		needs := shared.GetSlotNeeds(&resource, matcher)
+		needs.Needed = resource.Replicas
+		return 0, placements, needs, nil
+	}
+	rlog.Debugf("         Scheduling slot found at level %s\n", slot.Type)
+
+	// The types we need to see are the slot, and those needed in it
+	template := shared.GetSlotNeeds(&slot, matcher)
+	needed := map[string]bool{slot.Type: true}
+	for resourceType := range template.ResourcesOriginal {
+		needed[resourceType] = true
+	}
+	required := []string{}
+	for resourceType := range template.SubsystemsOriginal {
+		needed[resourceType] = true
+		required = append(required, resourceType)
+	}
+	neededTypes := make([]string, 0, len(needed))
+	for resourceType := range needed {
+		neededTypes = append(neededTypes, resourceType)
+	}
+
+	vertices, err := slotVertices(conn, cluster, slot.Type, neededTypes)
+	if err != nil {
+		return 0, placements, template, err
+	}
+	subsystems, err := subsystemEdges(conn, cluster, required, descends(template))
+	if err != nil {
+		return 0, placements, template, err
+	}
+
+	// Each slot vertex is checked with fresh needs
+	unmet := template
+	unmet.Needed = slot.Replicas
+	found := int32(0)
+	for i := 0; i < len(vertices); {
+		root := vertices[i].root
+		needs := shared.GetSlotNeeds(&slot, matcher)
+		needs.Needed = slot.Replicas
+		placement := types.NewSlotPlacement(label, found)
+		satisfied := false
+
+		for ; i < len(vertices) && vertices[i].root == root; i++ {
+			if satisfied {
+				continue
+			}
+			vtx := vertices[i].vertex
+			vtx.Subsystems = subsystems[vertices[i].id]
+
+			// Is the vertex part of the slot? We check counts before it is counted
+			count, ok := needs.Resources[vtx.Type]
+			_, isRequired := needs.Subsystems[vtx.Type]
+			isCounted := (ok && count > 0) || isRequired || vtx.Type == slot.Type
+
+			if !shared.CheckVertex(needs, vtx) {
+				continue
+			}
+			if isCounted {
+				placement.Vertices = append(placement.Vertices, vtx.NodeId)
+				for _, edge := range shared.SatisfyingEdges(needs, vtx) {
+					placement.AddSubsystemVertex(edge.Subsystem, edge.Vertex.NodeId)
+				}
+			}
+			satisfied = needs.AllSatisfied()
+		}
+		if !satisfied {
+			unmet = needs
+			continue
+		}
+		if found < slot.Replicas {
+			placements = append(placements, *placement)
+		}
+		found += 1
+		if found >= slot.Replicas && !countAll {
+			break
+		}
+	}
+	return found, placements, unmet, nil
+}
+
+// findSlot returns the first resource (in the resource or under it)
+// with replicas, which is the slot
+func findSlot(resource v1.Resource) (v1.Resource, bool) {
+	if resource.Replicas != 0 {
+		return resource, true
+	}
+	for _, with := range resource.With {
+		slot, ok := findSlot(with)
+		if ok {
+			return slot, true
+		}
+	}
+	return resource, false
+}
+
+// resourceCounts counts vertices by type in the dominant subsystem of a cluster
+func resourceCounts(conn *sql.DB, cluster string) (map[string]int64, error) {
+	counts := map[string]int64{}
+	rows, err := conn.Query(
+		"SELECT type, COUNT(*) FROM vertices WHERE cluster = ? AND subsystem = ? GROUP BY type",
+		cluster, types.DefaultDominantSubsystem,
+	)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var resourceType string
+		var count int64
+		err := rows.Scan(&resourceType, &count)
+		if err != nil {
+			return counts, err
+		}
+		counts[resourceType] = count
+	}
+	return counts, rows.Err()
+}
+
+// A slotVertex is a vertex under (or at) a slot vertex, the root
+type slotVertex struct {
+	root   int64
+	id     int64
+	vertex *types.Vertex
+}
+
+// slotVertices returns vertices of the needed types under each slot vertex
+// with their metadata
+func slotVertices(conn *sql.DB, cluster, slotType string, neededTypes []string) ([]slotVertex, error) {
+	vertices := []slotVertex{}
+	args := []any{cluster, types.DefaultDominantSubsystem, slotType, types.DefaultDominantSubsystem}
+	for _, resourceType := range neededTypes {
+		args = append(args, resourceType)
+	}
+	rows, err := conn.Query(fmt.Sprintf(slotQuery, placeholders(len(neededTypes))), args...)
+	if err != nil {
+		return vertices, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var meta string
+		entry := slotVertex{vertex: &types.Vertex{Metadata: metadata.Metadata{}}}
+		err := rows.Scan(&entry.root, &entry.id, &entry.vertex.NodeId, &entry.vertex.Type, &entry.vertex.Size, &meta)
+		if err != nil {
+			return vertices, err
+		}
+
+		// Metadata is needed for requirements on the vertex itself
+		err = json.Unmarshal([]byte(meta), &entry.vertex.Metadata)
+		if err != nil {
+			return vertices, err
+		}
+		entry.vertex.Identifier = int(entry.id)
+		vertices = append(vertices, entry)
+	}
+	return vertices, rows.Err()
+}
+
+// subsystemEdges returns edges to other subsystems for dominant vertices
+// of some types, by vertex id and then subsystem. If descend is true,
+// the subsystem vertices also have their edges within the subsystem.
+func subsystemEdges(
+	conn *sql.DB,
+	cluster string,
+	resourceTypes []string,
+	descend bool,
+) (map[int64]map[string]map[int]*types.Edge, error) {
+
+	edges := map[int64]map[string]map[int]*types.Edge{}
+	if len(resourceTypes) == 0 {
+		return edges, nil
+	}
+	args := []any{cluster, types.DefaultDominantSubsystem, types.DefaultDominantSubsystem}
+	for _, resourceType := range resourceTypes {
+		args = append(args, resourceType)
+	}
+	rows, err := conn.Query(fmt.Sprintf(subsystemQuery, placeholders(len(resourceTypes))), args...)
+	if err != nil {
+		return edges, err
+	}
+	defer rows.Close()
+
+	// Subsystem vertices are shared by the dominant vertices they are connected to
+	vertices := map[int]*types.Vertex{}
+	for rows.Next() {
+		var source int64
+		var subsystem string
+		vtx, err := scanVertex(rows, vertices, &source, &subsystem)
+		if err != nil {
+			return edges, err
+		}
+		_, ok := edges[source]
+		if !ok {
+			edges[source] = map[string]map[int]*types.Edge{}
+		}
+		_, ok = edges[source][subsystem]
+		if !ok {
+			edges[source][subsystem] = map[int]*types.Edge{}
+		}
+		edges[source][subsystem][vtx.Identifier] = &types.Edge{
+			Vertex:    vtx,
+			Relation:  types.ContainsRelation,
+			Subsystem: subsystem,
+		}
+	}
+	err = rows.Err()
+	if err != nil || !descend {
+		return edges, err
+	}
+	return edges, internalEdges(conn, args, len(resourceTypes), vertices)
+}
+
+// internalEdges adds the edges within subsystems below the vertices, which
+// a requirement with a path follows
+func internalEdges(conn *sql.DB, args []any, nTypes int, vertices map[int]*types.Vertex) error {
+	rows, err := conn.Query(fmt.Sprintf(internalQuery, placeholders(nTypes)), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source int64
+		vtx, err := scanVertex(rows, vertices, &source)
+		if err != nil {
+			return err
+		}
+		parent, ok := vertices[int(source)]
+		if !ok {
+			parent = &types.Vertex{Identifier: int(source)}
+			vertices[int(source)] = parent
+		}
+		if parent.Edges == nil {
+			parent.Edges = map[int]*types.Edge{}
+		}
+		parent.Edges[vtx.Identifier] = &types.Edge{
+			Vertex:   vtx,
+			Relation: types.ContainsRelation,
+		}
+	}
+	return rows.Err()
+}
+
+// scanVertex scans a row that ends with a vertex (id, nid, type, size, unit,
+// and metadata) after the destinations given. A vertex we have seen before
+// is returned from the lookup, and updated with the row.
+func scanVertex(rows *sql.Rows, vertices map[int]*types.Vertex, dest ...any) (*types.Vertex, error) {
+	var id int
+	var meta string
+	scanned := types.Vertex{}
+	dest = append(dest, &id, &scanned.NodeId, &scanned.Type, &scanned.Size, &scanned.Unit, &meta)
+	err := rows.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	vtx, ok := vertices[id]
+	if !ok {
+		vtx = &types.Vertex{}
+		vertices[id] = vtx
+	}
+	vtx.Identifier = id
+	vtx.NodeId, vtx.Type, vtx.Size, vtx.Unit = scanned.NodeId, scanned.Type, scanned.Size, scanned.Unit
+	vtx.Metadata = metadata.Metadata{}
+	return vtx, json.Unmarshal([]byte(meta), &vtx.Metadata)
+}
+
+// descends determines if any needs of a slot follow a path into a subsystem
+func descends(needs *types.ResourceNeeds) bool {
+	for _, matchNeeds := range needs.SubsystemsOriginal {
+		if match.Descends(matchNeeds) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholders returns a list of n parameters for a query
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
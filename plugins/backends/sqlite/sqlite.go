@@ -0,0 +1,384 @@
+package sqlite
+
+// The rainbow sqlite backend - a graph that persists without a service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/graph/backend"
+	rlog "github.com/converged-computing/rainbow/pkg/logger"
+	"github.com/converged-computing/rainbow/pkg/types"
+	"github.com/converged-computing/rainbow/pkg/utils"
+	"github.com/converged-computing/rainbow/plugins/algorithms/shared"
+	"google.golang.org/grpc"
+)
+
+type SQLiteGraph struct{}
+
+var (
+	description = "sqlite graph database for rainbow, persisted to a file"
+	sqliteName  = "sqlite"
+
+	// The database file, and the connection opened by Init
+	databaseFile = "rainbow-graph.db"
+	conn         *sql.DB
+)
+
+func (g SQLiteGraph) Name() string {
+	return sqliteName
+}
+
+func (g SQLiteGraph) Description() string {
+	return description
+}
+
+// AddCluster adds a cluster (the dominant subsystem) to the graph
+func (g SQLiteGraph) AddCluster(
+	name string,
+	nodes *jgf.JsonGraph,
+	subsystem string,
+) error {
+	if subsystem == "" {
+		subsystem = types.DefaultDominantSubsystem
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// One cluster per name, like the other backends
+	exists, err := hasSubsystem(tx, name, subsystem)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("cluster graph %s already exists and cannot be added again", name)
+	}
+	lookup, err := addNodes(tx, name, nodes, subsystem)
+	if err != nil {
+		return err
+	}
+	_, err = addEdges(tx, name, nodes, subsystem, lookup)
+	if err != nil {
+		return err
+	}
+	log.Printf("We have made a sqlite graph (subsystem %s) with %d vertices!", subsystem, len(lookup))
+	return tx.Commit()
+}
+
+// AddSubsystem adds a subsystem to a cluster in the graph
+func (g SQLiteGraph) AddSubsystem(
+	name string,
+	nodes *jgf.JsonGraph,
+	subsystem string,
+) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := hasSubsystem(tx, name, types.DefaultDominantSubsystem)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cluster %s does not exist", name)
+	}
+	lookup, err := addNodes(tx, name, nodes, subsystem)
+	if err != nil {
+		return err
+	}
+	count, err := addEdges(tx, name, nodes, subsystem, lookup)
+	if err != nil {
+		return err
+	}
+	log.Printf("We have made a sqlite graph (subsystem %s) with %d vertices, with %d connections to the dominant!", subsystem, len(lookup), count)
+	return tx.Commit()
+}
+
+// DeleteCluster removes a cluster, its subsystems, and state
+func (g SQLiteGraph) DeleteCluster(name string) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM subsystems WHERE cluster = ?", name)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("cluster graph %s does not exist", name)
+	}
+	_, err = tx.Exec("DELETE FROM states WHERE cluster = ?", name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteSubsystem removes a subsystem from a cluster
+// Without the dominant subsystem there is no cluster, so it is deleted too
+func (g SQLiteGraph) DeleteSubsystem(name, subsystem string) error {
+	if subsystem == types.DefaultDominantSubsystem {
+		return g.DeleteCluster(name)
+	}
+	result, err := conn.Exec("DELETE FROM subsystems WHERE cluster = ? AND name = ?", name, subsystem)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("cluster graph %s does not have subsystem %s", name, subsystem)
+	}
+	return nil
+}
+
+// UpdateState updates the state of a cluster. We always update old values
+func (g SQLiteGraph) UpdateState(
+	name string,
+	payload string,
+) error {
+	update := types.ClusterState{}
+	err := json.Unmarshal([]byte(payload), &update)
+	if err != nil {
+		return err
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	states, err := getStates(tx, []string{name})
+	if err != nil {
+		return err
+	}
+	state := states[name]
+	for key, value := range update {
+		rlog.Debugf("Updating state %s to %v\n", key, value)
+		state[key] = value
+	}
+	out, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO states (cluster, state) VALUES (?, ?) ON CONFLICT(cluster) DO UPDATE SET state = excluded.state",
+		name, string(out),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetStates for a list of clusters
+func (g SQLiteGraph) GetStates(names []string) (map[string]types.ClusterState, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return map[string]types.ClusterState{}, err
+	}
+	defer tx.Rollback()
+	return getStates(tx, names)
+}
+
+// getStates returns states for clusters, and errors if one is not known
+func getStates(tx *sql.Tx, names []string) (map[string]types.ClusterState, error) {
+	states := map[string]types.ClusterState{}
+	for _, name := range names {
+		exists, err := hasSubsystem(tx, name, types.DefaultDominantSubsystem)
+		if err != nil {
+			return states, err
+		}
+		if !exists {
+			return states, fmt.Errorf("cluster %s does not exist", name)
+		}
+		state := types.ClusterState{}
+		var payload string
+		err = tx.QueryRow("SELECT state FROM states WHERE cluster = ?", name).Scan(&payload)
+		if err != nil && err != sql.ErrNoRows {
+			return states, err
+		}
+		if payload != "" {
+			err = json.Unmarshal([]byte(payload), &state)
+			if err != nil {
+				return states, err
+			}
+		}
+		states[name] = state
+	}
+	return states, nil
+}
+
+// RegisterService does not add a service. The client reads the same
+// database file, so the cluster validator is not used.
+func (g SQLiteGraph) RegisterService(s *grpc.Server, validate backend.ClusterValidator) error {
+	log.Printf("🧠️ Registering sqlite graph database %s...\n", databaseFile)
+	return nil
+}
+
+// Satisfies - determine what clusters satisfy a jobspec request
+// Like the cypher backends, this runs from the client, which needs to
+// read the database file. We only search the clusters in the client
+// configuration (or all clusters, if there are none).
+func (g SQLiteGraph) Satisfies(
+	jobspec *js.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	explain bool,
+	clusters map[string]string,
+) (*types.SatisfyResult, error) {
+
+	matches := types.NewSatisfyResult()
+	names, err := clusterNames(utils.Keys(clusters))
+	if err != nil {
+		return matches, err
+	}
+	for _, name := range names {
+		result, err := moldCluster(conn, name, jobspec, matcher)
+		if err != nil {
+			return matches, err
+		}
+		if result.isMatch {
+			matches.Clusters = append(matches.Clusters, name)
+			matches.Placements[name] = result.placements
+			if len(result.sizes) > 0 {
+				matches.Sizes[name] = result.sizes
+			}
+			continue
+		}
+		for _, explanation := range result.explanations {
+			rlog.Debugf("  match: 🎯️ cluster %s is NOT a match: %s\n", name, explanation.String())
+		}
+		if explain {
+			matches.Mismatches[name] = result.explanations
+		}
+	}
+	matches.TotalClusters = int32(len(names))
+	matches.TotalMatches = int32(len(matches.Clusters))
+	matches.TotalMismatches = matches.TotalClusters - matches.TotalMatches
+	rlog.Debugf("\nMatches: %s\n", matches.Clusters)
+
+	// Matches are scored by the preferences they satisfy, if there are any
+	matches.Scores, err = shared.ScoreMatches(jobspec, matcher, matches.Clusters, func(required *js.Jobspec, names []string) ([]string, error) {
+		return satisfyPreference(required, matcher, names)
+	})
+	return matches, err
+}
+
+// satisfyPreference returns the clusters that match a jobspec with a
+// preference required
+func satisfyPreference(
+	jobspec *js.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	names []string,
+) ([]string, error) {
+
+	matches := []string{}
+	for _, name := range names {
+		result, err := searchCluster(conn, name, jobspec, matcher, false)
+		if err != nil {
+			return matches, err
+		}
+		if result.isMatch {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+// Capacity determines how many copies of a jobspec each cluster can host
+func (g SQLiteGraph) Capacity(
+	jobspec *js.Jobspec,
+	matcher algorithm.MatchAlgorithm,
+	names []string,
+) (map[string]int32, error) {
+
+	capacity := map[string]int32{}
+	known, err := clusterNames(names)
+	if err != nil {
+		return capacity, err
+	}
+	if len(names) > len(known) {
+		missing := utils.Diff(names, known)
+		return capacity, fmt.Errorf("cluster %s does not exist", missing[0])
+	}
+	for _, name := range known {
+		result, err := searchCluster(conn, name, jobspec, matcher, true)
+		if err != nil {
+			return capacity, err
+		}
+		rlog.Debugf("  capacity: cluster %s can fit %d copies\n", name, result.capacity)
+		capacity[name] = result.capacity
+	}
+	return capacity, nil
+}
+
+// clusterNames returns the names of known clusters, in order
+// If names are provided, only those that are known are returned
+func clusterNames(names []string) ([]string, error) {
+	known := []string{}
+	rows, err := conn.Query(
+		"SELECT cluster FROM subsystems WHERE name = ? ORDER BY cluster",
+		types.DefaultDominantSubsystem,
+	)
+	if err != nil {
+		return known, err
+	}
+	defer rows.Close()
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return known, err
+		}
+		if len(names) == 0 || wanted[name] {
+			known = append(known, name)
+		}
+	}
+	return known, rows.Err()
+}
+
+// Init opens the database file, which can be set with the file option
+func (g SQLiteGraph) Init(
+	options map[string]string,
+) error {
+	file, ok := options["file"]
+	if ok {
+		databaseFile = file
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	var err error
+	conn, err = connect(databaseFile)
+	return err
+}
+
+// Add the backend to be known to rainbow
+func init() {
+	graph := SQLiteGraph{}
+	backend.Register(graph)
+}
@@ -0,0 +1,277 @@
+package sqlite
+
+// Check that the sqlite graph persists in its file when it is opened again,
+// that deletions cascade to vertices and edges, and that state is merged.
+// go test ./plugins/backends/sqlite
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	js "github.com/compspec/jobspec-go/pkg/nextgen/v1"
+	jgf "github.com/converged-computing/jsongraph-go/jsongraph/v2/graph"
+	"github.com/converged-computing/rainbow/pkg/graph"
+	"github.com/converged-computing/rainbow/pkg/graph/algorithm"
+	"github.com/converged-computing/rainbow/pkg/types"
+
+	_ "github.com/converged-computing/rainbow/plugins/algorithms/match"
+)
+
+var examples = filepath.Join("..", "..", "..", "docs", "examples", "scheduler")
+
+// openGraph initializes the backend with a database file in a new directory
+func openGraph(t *testing.T) (SQLiteGraph, string) {
+	g := SQLiteGraph{}
+	file := filepath.Join(t.TempDir(), "graph.db")
+	err := g.Init(map[string]string{"file": file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return g, file
+}
+
+// readNodes reads a json graph from the scheduler examples
+func readNodes(t *testing.T, filename string) jgf.JsonGraph {
+	nodes, _, err := graph.ReadNodeJsonGraph(filepath.Join(examples, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+// countRows counts the rows of a query on the database
+func countRows(t *testing.T, query string, args ...any) int {
+	var count int
+	err := conn.QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestPersistence(t *testing.T) {
+	g, file := openGraph(t)
+	nodes := readNodes(t, "cluster-nodes.json")
+	ioNodes := readNodes(t, "cluster-io-subsystem.json")
+	err := g.AddCluster("keebler", &nodes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.AddSubsystem("keebler", &ioNodes, "io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.UpdateState("keebler", `{"cost-per-node": 12}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices := countRows(t, "SELECT COUNT(*) FROM vertices")
+	edges := countRows(t, "SELECT COUNT(*) FROM edges")
+
+	// A new file does not have the cluster
+	err = g.Init(map[string]string{"file": filepath.Join(t.TempDir(), "other.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.GetStates([]string{"keebler"})
+	if err == nil {
+		t.Errorf("a new database should not have cluster keebler")
+	}
+
+	// Opening the same file again has the cluster, its subsystem, and state
+	err = g.Init(map[string]string{"file": file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states, err := g.GetStates([]string{"keebler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(states["keebler"], types.ClusterState{"cost-per-node": 12.0}) {
+		t.Errorf("state is %v after opening the database again", states["keebler"])
+	}
+	if found := countRows(t, "SELECT COUNT(*) FROM vertices"); found != vertices {
+		t.Errorf("there are %d vertices after opening the database again, expected %d", found, vertices)
+	}
+	if found := countRows(t, "SELECT COUNT(*) FROM edges"); found != edges {
+		t.Errorf("there are %d edges after opening the database again, expected %d", found, edges)
+	}
+	err = g.AddCluster("keebler", &nodes, "")
+	if err == nil {
+		t.Errorf("registering keebler again after opening the database should be an error")
+	}
+
+	// A search needs the subsystem that was saved
+	jobspec, err := js.LoadJobspecYaml(filepath.Join(examples, "jobspec-io.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := g.Satisfies(jobspec, algorithm.GetOrFail("match"), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Clusters, []string{"keebler"}) {
+		t.Errorf("clusters are %v after opening the database again, expected keebler", result.Clusters)
+	}
+}
+
+func TestCascadeDelete(t *testing.T) {
+	g, _ := openGraph(t)
+	nodes := readNodes(t, "cluster-nodes.json")
+	ioNodes := readNodes(t, "cluster-io-subsystem.json")
+	for _, name := range []string{"keebler", "spack"} {
+		err := g.AddCluster(name, &nodes, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = g.AddSubsystem(name, &ioNodes, "io")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = g.UpdateState(name, `{"ready": true}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	vertices := countRows(t, "SELECT COUNT(*) FROM vertices WHERE cluster = ? AND subsystem = ?", "keebler", types.DefaultDominantSubsystem)
+	if vertices == 0 {
+		t.Fatalf("cluster keebler has no vertices")
+	}
+
+	// Deleting a subsystem deletes its vertices and the edges to them,
+	// including edges from the dominant subsystem
+	err := g.DeleteSubsystem("keebler", "io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioVertices := "SELECT COUNT(*) FROM vertices WHERE cluster = ? AND subsystem = ?"
+	if found := countRows(t, ioVertices, "keebler", "io"); found != 0 {
+		t.Errorf("there are %d io vertices for keebler after deleting the subsystem", found)
+	}
+	if found := countRows(t, ioVertices, "spack", "io"); found == 0 {
+		t.Errorf("io vertices for spack were deleted with the subsystem for keebler")
+	}
+	clusterEdges := `SELECT COUNT(*) FROM edges e JOIN vertices v ON e.target = v.id
+		WHERE v.cluster = ? AND e.subsystem = ?`
+	if found := countRows(t, clusterEdges, "keebler", "io"); found != 0 {
+		t.Errorf("there are %d io edges for keebler after deleting the subsystem", found)
+	}
+	if found := countRows(t, "SELECT COUNT(*) FROM vertices WHERE cluster = ? AND subsystem = ?", "keebler", types.DefaultDominantSubsystem); found != vertices {
+		t.Errorf("there are %d cluster vertices for keebler after deleting io, expected %d", found, vertices)
+	}
+	err = g.DeleteSubsystem("keebler", "io")
+	if err == nil {
+		t.Errorf("deleting a subsystem again should be an error")
+	}
+
+	// Deleting the cluster deletes everything for it, and nothing else
+	totalEdges := countRows(t, "SELECT COUNT(*) FROM edges")
+	keeblerEdges := countRows(t, "SELECT COUNT(*) FROM edges e JOIN vertices v ON e.source = v.id WHERE v.cluster = ?", "keebler")
+	err = g.DeleteCluster("keebler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]string{
+		"subsystems": "SELECT COUNT(*) FROM subsystems WHERE cluster = ?",
+		"vertices":   "SELECT COUNT(*) FROM vertices WHERE cluster = ?",
+		"edges":      "SELECT COUNT(*) FROM edges e JOIN vertices v ON e.source = v.id WHERE v.cluster = ?",
+		"states":     "SELECT COUNT(*) FROM states WHERE cluster = ?",
+	}
+	for table, query := range counts {
+		if found := countRows(t, query, "keebler"); found != 0 {
+			t.Errorf("there are %d %s for keebler after deleting it", found, table)
+		}
+		if found := countRows(t, query, "spack"); found == 0 {
+			t.Errorf("%s for spack were deleted with keebler", table)
+		}
+	}
+	if found := countRows(t, "SELECT COUNT(*) FROM edges"); found != totalEdges-keeblerEdges {
+		t.Errorf("there are %d edges left, expected %d for spack", found, totalEdges-keeblerEdges)
+	}
+	err = g.DeleteCluster("keebler")
+	if err == nil {
+		t.Errorf("deleting a cluster again should be an error")
+	}
+}
+
+func TestUpdateState(t *testing.T) {
+	g, _ := openGraph(t)
+	nodes := readNodes(t, "cluster-nodes.json")
+	for _, name := range []string{"keebler", "spack"} {
+		err := g.AddCluster(name, &nodes, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name     string
+		cluster  string
+		payload  string
+		wantErr  bool
+		expected map[string]types.ClusterState
+	}{
+		{
+			name:     "no state before an update",
+			expected: map[string]types.ClusterState{"keebler": {}, "spack": {}},
+		},
+		{
+			name:     "update saves types",
+			cluster:  "keebler",
+			payload:  `{"cost-per-node": 12, "ready": true, "region": "east"}`,
+			expected: map[string]types.ClusterState{"keebler": {"cost-per-node": 12.0, "ready": true, "region": "east"}, "spack": {}},
+		},
+		{
+			name:     "update merges with saved state",
+			cluster:  "keebler",
+			payload:  `{"cost-per-node": 10, "max-jobs": 4}`,
+			expected: map[string]types.ClusterState{"keebler": {"cost-per-node": 10.0, "ready": true, "region": "east", "max-jobs": 4.0}, "spack": {}},
+		},
+		{
+			name:     "update one cluster",
+			cluster:  "spack",
+			payload:  `{"ready": false}`,
+			expected: map[string]types.ClusterState{"keebler": {"cost-per-node": 10.0, "ready": true, "region": "east", "max-jobs": 4.0}, "spack": {"ready": false}},
+		},
+		{
+			name:    "update unknown cluster",
+			cluster: "unknown",
+			payload: `{"ready": true}`,
+			wantErr: true,
+		},
+		{
+			name:    "update with invalid payload",
+			cluster: "keebler",
+			payload: `{"ready": `,
+			wantErr: true,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.cluster != "" {
+				err := g.UpdateState(step.cluster, step.payload)
+				if step.wantErr {
+					if err == nil {
+						t.Fatalf("expected an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			states, err := g.GetStates([]string{"keebler", "spack"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(states, step.expected) {
+				t.Errorf("states are %v, expected %v", states, step.expected)
+			}
+		})
+	}
+	if found := countRows(t, "SELECT COUNT(*) FROM states WHERE cluster = ?", "unknown"); found != 0 {
+		t.Errorf("an update for an unknown cluster saved state")
+	}
+}
@@ -166,6 +166,20 @@ func (s ConstraintSelection) Select(
 	return clusters, nil
 }
 
+// UsesParameter determines if a step of any priority refers to a parameter
+func (s ConstraintSelection) UsesParameter(name string) bool {
+	for _, priority := range opts {
+		for _, steps := range priority.Steps {
+			for stepName, logic := range steps {
+				if stepUsesParameter(stepName, logic, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // Init provides extra initialization functionality, if needed
 // The in memory database can take a backup file if desired
 func (s ConstraintSelection) Init(options map[string]string) error {
@@ -61,13 +61,13 @@ func filterStep(
 
 		// Try to evaluate the expression
 		passes, err := expression.Evaluate(parameters)
-		if passes == "false" {
-			continue
-		}
 		if err != nil {
 			rlog.Warningf("    issue with filter evaluation: %s\n", err)
 			continue
 		}
+		if passes != true {
+			continue
+		}
 		filtered = append(filtered, cluster)
 	}
 	return filtered, nil
@@ -173,6 +173,34 @@ func sortAscending(
 	return final, nil
 }
 
+// stepUsesParameter determines if the logic for a step refers to a parameter.
+// If we cannot parse the expression, we assume that it does.
+func stepUsesParameter(stepName, logic, name string) bool {
+	switch stepName {
+	case "sort_descending", "sort_ascending":
+		return logic == name
+	case "calc":
+		parts := strings.Split(logic, "=")
+		if len(parts) != 2 {
+			return false
+		}
+		logic = parts[1]
+	case "filter":
+	default:
+		return false
+	}
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(logic, functions)
+	if err != nil {
+		return true
+	}
+	for _, variable := range expression.Vars() {
+		if variable == name {
+			return true
+		}
+	}
+	return false
+}
+
 // prepareParameters is a shared function that steps can use for equation parameters
 func prepareParameters(jobspec *js.Jobspec, state *types.ClusterState) map[string]interface{} {
 
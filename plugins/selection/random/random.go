@@ -45,6 +45,11 @@ func (s RandomSelection) Select(
 	return []string{contenders[idx]}, nil
 }
 
+// UsesParameter is false, as random selection does not read states
+func (s RandomSelection) UsesParameter(name string) bool {
+	return false
+}
+
 // Init provides extra initialization functionality, if needed
 // The in memory database can take a backup file if desired
 func (s RandomSelection) Init(options map[string]string) error {